@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveLimiter is a fixed-window limiter whose limit follows the load.
+// At the end of every window it folds the rate of accepted requests into an
+// exponentially weighted moving average, then scales the limit by
+// TargetRate divided by that average: a downstream taking more than
+// TargetRate gets a tighter limit, and one taking less gets a looser one,
+// up to Limit.
+type AdaptiveLimiter struct {
+	// Window is the length of each window.
+	Window time.Duration
+	// Limit is the largest number of requests allowed per window, and the
+	// limit of the first window.
+	Limit uint64
+	// TargetRate is the accepted requests per second to aim for.
+	TargetRate float64
+	// Alpha, between 0 and 1, is the weight of the latest window in the
+	// moving average. Higher values adapt faster but follow noise more.
+	Alpha float64
+
+	limit       uint64  // current effective limit
+	rate        float64 // moving average of accepted requests per second
+	counter     uint64
+	windowStart time.Time
+	mu          *sync.Mutex
+	stop        bool
+}
+
+// Validate checks that the window, limit, target rate and alpha are usable.
+func (al *AdaptiveLimiter) Validate() error {
+	if al.Window <= 0 {
+		return errors.New("window must be greater than zero")
+	}
+	if al.Limit == 0 {
+		return errors.New("limit must be greater than zero")
+	}
+	if !(al.TargetRate > 0) || math.IsInf(al.TargetRate, 0) {
+		return errors.New("target rate must be a positive number")
+	}
+	if !(al.Alpha > 0 && al.Alpha <= 1) {
+		return errors.New("alpha must be greater than zero and at most one")
+	}
+	return nil
+}
+
+// Do validates the configuration and starts the first window.
+func (al *AdaptiveLimiter) Do() error {
+	if err := al.Validate(); err != nil {
+		return err
+	}
+	if al.mu == nil {
+		al.mu = &sync.Mutex{}
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if al.stop {
+		return ErrStopped
+	}
+	if !al.windowStart.IsZero() {
+		return errors.New("rate limiter already started")
+	}
+	al.limit = al.Limit
+	al.windowStart = time.Now()
+	return nil
+}
+
+// Accept reports whether the request fits in the current window's
+// effective limit and, if so, counts it.
+func (al *AdaptiveLimiter) Accept() bool {
+	return al.acceptAt(time.Now())
+}
+
+// EffectiveLimit returns the limit of the current window.
+func (al *AdaptiveLimiter) EffectiveLimit() uint64 {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.advanceLocked(time.Now())
+	return al.limit
+}
+
+// Rate returns the moving average of accepted requests per second over the
+// windows that have ended.
+func (al *AdaptiveLimiter) Rate() float64 {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.advanceLocked(time.Now())
+	return al.rate
+}
+
+// Stop makes Accept reject every request. It may be called before Do.
+func (al *AdaptiveLimiter) Stop() {
+	if al.mu == nil {
+		al.stop = true
+		return
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.stop = true
+}
+
+func (al *AdaptiveLimiter) acceptAt(now time.Time) bool {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if al.stop {
+		return false
+	}
+	al.advanceLocked(now)
+	if al.counter >= al.limit {
+		return false
+	}
+	al.counter++
+	return true
+}
+
+// advanceLocked ends every window that is over by now, updating the moving
+// average and the limit for each. al.mu must be held.
+func (al *AdaptiveLimiter) advanceLocked(now time.Time) {
+	ended := now.Sub(al.windowStart) / al.Window
+	if ended <= 0 {
+		return
+	}
+	// After many idle windows the average has decayed to nothing anyway.
+	ended = min(ended, 64)
+	for range ended {
+		observed := float64(al.counter) / al.Window.Seconds()
+		al.rate = al.Alpha*observed + (1-al.Alpha)*al.rate
+		al.counter = 0
+		al.adjustLocked()
+	}
+	al.windowStart = al.windowStart.Add(now.Sub(al.windowStart) / al.Window * al.Window)
+}
+
+// adjustLocked scales the limit by how far the average is from the target.
+// al.mu must be held.
+func (al *AdaptiveLimiter) adjustLocked() {
+	if al.rate <= 0 {
+		al.limit = al.Limit
+		return
+	}
+	next := math.Ceil(float64(al.limit) * al.TargetRate / al.rate)
+	al.limit = uint64(min(max(next, 1), float64(al.Limit)))
+}
@@ -0,0 +1,47 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestAdaptiveLimiterFollowsTarget(t *testing.T) {
+	al := &ratelimit.AdaptiveLimiter{Window: time.Second, Limit: 100, TargetRate: 20, Alpha: 0.5}
+	if err := al.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer al.Stop()
+	start := al.WindowStart()
+
+	// Offer far more than the target every window.
+	var accepted int
+	for w := range 40 {
+		at := start.Add(time.Duration(w) * time.Second)
+		accepted = 0
+		for range 200 {
+			if al.AcceptAt(at) {
+				accepted++
+			}
+		}
+		if w == 0 && accepted != 100 {
+			t.Fatalf("first window accepted %d, want the full limit of 100", accepted)
+		}
+	}
+	if accepted < 15 || accepted > 25 {
+		t.Fatalf("accepted %d per window under heavy load, want about the target of 20", accepted)
+	}
+
+	// With light load the limit opens back up to Limit.
+	start = start.Add(40 * time.Second)
+	for w := range 40 {
+		at := start.Add(time.Duration(w) * time.Second)
+		for range 5 {
+			al.AcceptAt(at)
+		}
+	}
+	if got := al.EffectiveLimitAt(start.Add(40 * time.Second)); got != 100 {
+		t.Fatalf("limit %d under light load, want 100", got)
+	}
+}
@@ -0,0 +1,100 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// newBenchLimiter returns a started limiter whose limit is never reached, so
+// the benchmarks measure the accepting path.
+func newBenchLimiter(b *testing.B) *ratelimit.FixedWindow {
+	b.Helper()
+	fw := ratelimit.NewFixedWindow(time.Hour, 1<<62)
+	if err := fw.Do(); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(fw.Stop)
+	return fw
+}
+
+func BenchmarkAccept(b *testing.B) {
+	fw := newBenchLimiter(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		fw.Accept()
+	}
+}
+
+func BenchmarkAcceptParallel(b *testing.B) {
+	for _, p := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("goroutines=%dxGOMAXPROCS", p), func(b *testing.B) {
+			fw := newBenchLimiter(b)
+			b.ReportAllocs()
+			b.SetParallelism(p)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					fw.Accept()
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkAcceptParallelLocked records events, which makes every Accept
+// take the write lock, for comparison with the lock-free path measured by
+// BenchmarkAcceptParallel.
+func BenchmarkAcceptParallelLocked(b *testing.B) {
+	for _, p := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("goroutines=%dxGOMAXPROCS", p), func(b *testing.B) {
+			fw := &ratelimit.FixedWindow{Window: time.Hour, Limit: 1 << 62, EventBuffer: 1}
+			if err := fw.Do(); err != nil {
+				b.Fatal(err)
+			}
+			b.Cleanup(fw.Stop)
+			b.ReportAllocs()
+			b.SetParallelism(p)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					fw.Accept()
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkAcceptWindowReset moves to a new window every 100 requests, so it
+// includes the cost of rolling windows over.
+func BenchmarkAcceptWindowReset(b *testing.B) {
+	const perWindow = 100
+	fw := ratelimit.NewFixedWindow(time.Second, perWindow)
+	if err := fw.Do(); err != nil {
+		b.Fatal(err)
+	}
+	defer fw.Stop()
+	now := time.Now()
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		if i%perWindow == 0 {
+			now = now.Add(time.Second)
+		}
+		fw.AllowAt(now)
+		i++
+	}
+}
+
+func BenchmarkAcceptRejected(b *testing.B) {
+	fw := ratelimit.NewFixedWindow(time.Hour, 1)
+	if err := fw.Do(); err != nil {
+		b.Fatal(err)
+	}
+	defer fw.Stop()
+	fw.Accept()
+	b.ReportAllocs()
+	for b.Loop() {
+		fw.Accept()
+	}
+}
@@ -0,0 +1,25 @@
+package ratelimit
+
+import "time"
+
+// Clock tells a limiter the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// WithClock makes the limiter read the time from c instead of the system
+// clock.
+func WithClock(c Clock) Option {
+	return func(fw *FixedWindow) { fw.Clock = c }
+}
+
+func (fw *FixedWindow) now() time.Time {
+	if fw.Clock != nil {
+		return fw.Clock.Now()
+	}
+	return systemClock{}.Now()
+}
@@ -0,0 +1,135 @@
+package ratelimit_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestFakeClockRollover(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 2, Clock: clock})
+	steps := []struct {
+		advance time.Duration
+		want    bool
+	}{
+		{0, true},
+		{0, true},
+		{59 * time.Second, false},
+		// The window ends exactly one minute after Do.
+		{time.Second, true},
+		{0, true},
+		{0, false},
+		// Several windows pass without a request.
+		{5 * time.Minute, true},
+	}
+	for i, s := range steps {
+		clock.Add(s.advance)
+		if got := fw.Accept(); got != s.want {
+			t.Fatalf("step %d at %v: got %v, want %v", i, clock.Now(), got, s.want)
+		}
+	}
+	if got, want := fw.NextResetTime(), newFakeClock().Now().Add(7*time.Minute); !got.Equal(want) {
+		t.Fatalf("next reset %v, want %v", got, want)
+	}
+}
+
+func TestAllowAtManyWindows(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Second, Limit: 3, Clock: clock})
+	before := runtime.NumGoroutine()
+	start := clock.Now()
+	for w := range 1000 {
+		at := start.Add(time.Duration(w) * time.Second)
+		for i := range 4 {
+			if got, want := fw.AllowAt(at.Add(time.Duration(i)*time.Millisecond)), i < 3; got != want {
+				t.Fatalf("window %d, request %d: got %v, want %v", w, i, got, want)
+			}
+		}
+	}
+	// Windows roll over lazily, so none of this started a goroutine.
+	if got := runtime.NumGoroutine(); got > before {
+		t.Fatalf("%d goroutines, want at most %d", got, before)
+	}
+	if resets, _ := fw.Stats(); resets != 999 {
+		t.Fatalf("%d resets, want 999", resets)
+	}
+}
+
+func TestNextResetTimeAdvances(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 1, Clock: clock})
+	want := clock.Now().Add(time.Minute)
+	for i := range 5 {
+		if got := fw.NextResetTime(); !got.Equal(want) {
+			t.Fatalf("window %d: next reset %v, want %v", i, got, want)
+		}
+		// Partway through the window the reset time stays put.
+		clock.Add(30 * time.Second)
+		if got := fw.NextResetTime(); !got.Equal(want) {
+			t.Fatalf("window %d, 30s in: next reset %v, want %v", i, got, want)
+		}
+		clock.Add(30 * time.Second)
+		want = want.Add(time.Minute)
+	}
+}
+
+func TestJitterSpreadsResets(t *testing.T) {
+	offsets := []time.Duration{10 * time.Second, 25 * time.Second}
+	var calls int
+	defer ratelimit.SetJitter(func(n int64) int64 {
+		if n != int64(30*time.Second) {
+			t.Errorf("jitter drawn below %v, want below %v", time.Duration(n), 30*time.Second)
+		}
+		d := offsets[calls]
+		calls++
+		return int64(d)
+	})()
+
+	clock := newFakeClock()
+	var resets []time.Time
+	for range offsets {
+		fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 1, Jitter: 30 * time.Second, Clock: clock})
+		resets = append(resets, fw.NextResetTime())
+	}
+	// Each first window ends early by its limiter's offset.
+	for i, offset := range offsets {
+		if want := clock.Now().Add(time.Minute - offset); !resets[i].Equal(want) {
+			t.Fatalf("limiter %d resets at %v, want %v", i, resets[i], want)
+		}
+	}
+	if resets[0].Equal(resets[1]) {
+		t.Fatalf("both limiters reset at %v", resets[0])
+	}
+}
+
+func TestResetOnFirstRequestAfterIdleGap(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 2, ResetOnFirstRequest: true, Clock: clock})
+	fw.AcceptN(2)
+
+	// Ninety seconds of silence, then a request starts the next window.
+	clock.Add(90 * time.Second)
+	requestAt := clock.Now()
+	if !fw.Accept() {
+		t.Fatal("first request after the gap rejected")
+	}
+	if got, want := fw.NextResetTime(), requestAt.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("next reset %v, want %v, a minute after the request", got, want)
+	}
+
+	// An aligned window would have reset at two minutes; this one has not.
+	clock.Add(35 * time.Second)
+	if !fw.Accept() {
+		t.Fatal("second request in the window rejected")
+	}
+	if fw.Accept() {
+		t.Fatal("request over the limit accepted, 35s after the window started")
+	}
+	clock.Add(25 * time.Second)
+	if !fw.Accept() {
+		t.Fatal("request a minute after the window started rejected")
+	}
+}
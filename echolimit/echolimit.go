@@ -0,0 +1,45 @@
+// Package echolimit provides Echo middleware for ratelimit limiters. It is
+// a module of its own, so importing ratelimit does not pull in Echo.
+package echolimit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/govi230/ratelimit"
+	"github.com/labstack/echo/v4"
+)
+
+// EchoMiddleware returns middleware that calls rl.Accept for every request
+// and fails it with a 429 echo.HTTPError when it is rejected.
+//
+// If rl, or a limiter it wraps, implements ratelimit.WindowReporter, every
+// response carries the X-RateLimit-* headers, and rejections carry
+// Retry-After.
+func EchoMiddleware(rl ratelimit.Limiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ok := rl.Accept()
+			if wr, isReporter := ratelimit.As[ratelimit.WindowReporter](rl); isReporter {
+				resetIn := seconds(wr.ResetIn())
+				h := c.Response().Header()
+				h.Set("X-RateLimit-Limit", strconv.FormatUint(wr.WindowLimit(), 10))
+				h.Set("X-RateLimit-Remaining", strconv.FormatUint(wr.Remaining(), 10))
+				h.Set("X-RateLimit-Reset", resetIn)
+				if !ok {
+					h.Set("Retry-After", resetIn)
+				}
+			}
+			if !ok {
+				return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+			}
+			return next(c)
+		}
+	}
+}
+
+// seconds formats d as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
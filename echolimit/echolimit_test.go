@@ -0,0 +1,65 @@
+package echolimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+	"github.com/govi230/ratelimit/echolimit"
+	"github.com/labstack/echo/v4"
+)
+
+func TestEchoMiddleware(t *testing.T) {
+	fw := ratelimit.NewFixedWindow(time.Hour, 1)
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+	e := echo.New()
+	e.Use(echolimit.EchoMiddleware(fw))
+	e.GET("/", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
+
+	for i, want := range []struct {
+		code       int
+		remaining  string
+		retryAfter string
+	}{
+		{http.StatusOK, "0", ""},
+		{http.StatusTooManyRequests, "0", "3600"},
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != want.code {
+			t.Fatalf("request %d: got %d, want %d", i, rec.Code, want.code)
+		}
+		h := rec.Header()
+		if got := h.Get("X-RateLimit-Limit"); got != "1" {
+			t.Fatalf("request %d: X-RateLimit-Limit %q, want 1", i, got)
+		}
+		if got := h.Get("X-RateLimit-Remaining"); got != want.remaining {
+			t.Fatalf("request %d: X-RateLimit-Remaining %q, want %q", i, got, want.remaining)
+		}
+		if got := h.Get("X-RateLimit-Reset"); got != "3600" {
+			t.Fatalf("request %d: X-RateLimit-Reset %q, want 3600", i, got)
+		}
+		if got := h.Get("Retry-After"); got != want.retryAfter {
+			t.Fatalf("request %d: Retry-After %q, want %q", i, got, want.retryAfter)
+		}
+	}
+}
+
+func TestEchoMiddlewareWithoutWindowReporter(t *testing.T) {
+	e := echo.New()
+	e.Use(echolimit.EchoMiddleware(ratelimit.AlwaysDeny{}))
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("got %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "" {
+		t.Fatalf("Retry-After %q without a WindowReporter", got)
+	}
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// FromEnv returns a FixedWindow, not yet started, configured from the
+// environment variables <prefix>_LIMIT, <prefix>_DURATION and
+// <prefix>_UNIT, e.g. API_LIMIT=100, API_DURATION=1 and API_UNIT=minute
+// for a prefix of "API". All three are required.
+func FromEnv(prefix string) (*FixedWindow, error) {
+	limit, err := envUint(prefix + "_LIMIT")
+	if err != nil {
+		return nil, err
+	}
+	duration, err := envUint(prefix + "_DURATION")
+	if err != nil {
+		return nil, err
+	}
+	unit, ok := os.LookupEnv(prefix + "_UNIT")
+	if !ok || unit == "" {
+		return nil, fmt.Errorf("%s_UNIT is not set", prefix)
+	}
+	fw := &FixedWindow{Duration: duration, Unit: unit, Limit: limit}
+	if err := fw.Validate(); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+// envUint parses the environment variable name as an unsigned integer. An
+// unset or empty variable is an error.
+func envUint(name string) (uint64, error) {
+	s, ok := os.LookupEnv(name)
+	if !ok || s == "" {
+		return 0, fmt.Errorf("%s is not set", name)
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", name, s)
+	}
+	return n, nil
+}
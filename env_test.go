@@ -0,0 +1,60 @@
+package ratelimit_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("API_LIMIT", "100")
+	t.Setenv("API_DURATION", "2")
+	t.Setenv("API_UNIT", "minute")
+	fw, err := ratelimit.FromEnv("API")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw.Limit != 100 || fw.Duration != 2 || fw.Unit != "minute" {
+		t.Fatalf("got limit %d, duration %d, unit %q, want 100, 2, minute", fw.Limit, fw.Duration, fw.Unit)
+	}
+	if fw.IsRunning() {
+		t.Fatal("FromEnv started the limiter")
+	}
+	startFixedWindow(t, fw)
+	if got := fw.ResetIn(); got <= time.Minute || got > 2*time.Minute {
+		t.Fatalf("resets in %v, want a two-minute window", got)
+	}
+
+}
+
+func TestFromEnvErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name                  string
+		limit, duration, unit string
+		want                  string
+	}{
+		{"missing limit", "", "1", "second", "LIMIT_LIMIT is not set"},
+		{"missing unit", "5", "1", "", "LIMIT_UNIT is not set"},
+		{"malformed limit", "ten", "1", "second", `LIMIT_LIMIT must be a non-negative integer, got "ten"`},
+		{"missing duration", "5", "", "second", "LIMIT_DURATION is not set"},
+		{"negative duration", "5", "-1", "second", `LIMIT_DURATION must be a non-negative integer, got "-1"`},
+		{"zero duration", "5", "0", "second", "duration must be greater than zero"},
+		{"unknown unit", "5", "1", "fortnight", "fortnight"},
+		{"zero limit", "0", "1", "second", "limit must be greater than zero"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("LIMIT_LIMIT", tc.limit)
+			t.Setenv("LIMIT_DURATION", tc.duration)
+			t.Setenv("LIMIT_UNIT", tc.unit)
+			fw, err := ratelimit.FromEnv("LIMIT")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tc.want)
+			}
+			if fw != nil {
+				t.Fatal("got a limiter along with the error")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import "time"
+
+// EventType says what happened in a limiter Event.
+type EventType int
+
+const (
+	// EventAccepted means a request was accepted.
+	EventAccepted EventType = iota
+	// EventRejected means a request was rejected.
+	EventRejected
+	// EventWindowReset means a new window started.
+	EventWindowReset
+	// EventStoreError means the limiter's Store failed. The request was
+	// accepted or rejected according to FailOpen.
+	EventStoreError
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAccepted:
+		return "accepted"
+	case EventRejected:
+		return "rejected"
+	case EventWindowReset:
+		return "window reset"
+	case EventStoreError:
+		return "store error"
+	}
+	return "unknown"
+}
+
+// Event is a single limiter decision, window reset or store failure.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Err is the Store error for EventStoreError events.
+	Err error
+	// Peak is the highest count of the window that ended, for
+	// EventWindowReset events.
+	Peak uint64
+}
+
+// WithEventBuffer enables Events with a channel of the given capacity.
+func WithEventBuffer(n int) Option {
+	return func(fw *FixedWindow) { fw.EventBuffer = n }
+}
+
+// Events returns the channel on which the limiter reports its decisions and
+// window resets, or nil if EventBuffer was not set before Do. The channel is
+// closed by Stop.
+//
+// Events are sent without blocking: when the channel is full, new events
+// are dropped rather than holding up Accept.
+func (fw *FixedWindow) Events() <-chan Event {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.events
+}
+
+// recordLocked reports the outcome of an accept attempt. fw.mu must be held.
+func (fw *FixedWindow) recordLocked(accepted bool, now time.Time) {
+	t := EventRejected
+	if accepted {
+		t = EventAccepted
+	}
+	fw.emit(Event{Type: t, Time: now})
+}
+
+// emit sends an event if there is room for it. fw.mu must be held.
+func (fw *FixedWindow) emit(e Event) {
+	if fw.events == nil || fw.stop {
+		return
+	}
+	select {
+	case fw.events <- e:
+	default:
+	}
+}
@@ -0,0 +1,110 @@
+package ratelimit_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// drain returns the events buffered in ch, which must have been closed.
+func drain(ch <-chan ratelimit.Event) []ratelimit.EventType {
+	var types []ratelimit.EventType
+	for e := range ch {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestEventsOrder(t *testing.T) {
+	clock := newFakeClock()
+	fw, err := ratelimit.New(ratelimit.WithWindow(time.Minute), ratelimit.WithLimit(1), ratelimit.WithEventBuffer(8), ratelimit.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Accept()
+	fw.Accept()
+	clock.Add(time.Minute)
+	fw.Accept()
+	fw.Stop()
+
+	got := drain(fw.Events())
+	want := []ratelimit.EventType{ratelimit.EventAccepted, ratelimit.EventRejected, ratelimit.EventWindowReset, ratelimit.EventAccepted}
+	if len(got) != len(want) {
+		t.Fatalf("events %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEventsDroppedWhenFull(t *testing.T) {
+	fw, err := ratelimit.New(ratelimit.WithWindow(time.Hour), ratelimit.WithLimit(1), ratelimit.WithEventBuffer(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nobody reads the channel, which must not hold up Accept.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			fw.Accept()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Accept blocked on a full event channel")
+	}
+	fw.Stop()
+	got := drain(fw.Events())
+	if len(got) != 2 || got[0] != ratelimit.EventAccepted || got[1] != ratelimit.EventRejected {
+		t.Fatalf("events %v, want the first two: [accepted rejected]", got)
+	}
+}
+
+func TestEventsDisabled(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	if fw.Events() != nil {
+		t.Fatal("Events returned a channel without EventBuffer")
+	}
+}
+
+func TestPeakLastWindow(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 10, EventBuffer: 32, Clock: clock})
+	// A burst that peaks at 8 before some of it is refunded.
+	fw.AcceptN(8)
+	fw.Refund(3)
+	clock.Add(time.Minute)
+	if got := fw.PeakLastWindow(); got != 8 {
+		t.Fatalf("peak after the burst %d, want 8", got)
+	}
+	// A quieter window.
+	fw.AcceptN(2)
+	clock.Add(time.Minute)
+	fw.Accept()
+	if got := fw.PeakLastWindow(); got != 2 {
+		t.Fatalf("peak after the quiet window %d, want 2", got)
+	}
+	// Several idle windows.
+	clock.Add(3 * time.Minute)
+	if got := fw.PeakLastWindow(); got != 0 {
+		t.Fatalf("peak after idle windows %d, want 0", got)
+	}
+	fw.Accept()
+	fw.Stop()
+
+	var peaks []uint64
+	for e := range fw.Events() {
+		if e.Type == ratelimit.EventWindowReset {
+			peaks = append(peaks, e.Peak)
+		}
+	}
+	if want := []uint64{8, 2, 0}; !slices.Equal(peaks, want) {
+		t.Fatalf("reset events carry peaks %v, want %v", peaks, want)
+	}
+}
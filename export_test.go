@@ -0,0 +1,61 @@
+package ratelimit
+
+import "time"
+
+// Hooks for the tests in package ratelimit_test, which decide at a given
+// time instead of sleeping.
+
+func (tb *TokenBucket) AcceptAt(now time.Time) bool { return tb.acceptAt(now) }
+
+func (sw *SlidingWindowLog) AcceptAt(now time.Time) bool { return sw.acceptAt(now) }
+
+// Has reports whether kl holds a limiter for key, without touching it.
+func (kl *KeyedLimiter) Has(key string) bool {
+	_, ok := kl.lookup(key)
+	return ok
+}
+
+func (g *GCRA) AcceptAt(now time.Time) bool { return g.acceptAt(now) }
+
+func (sc *SlidingWindowCounter) AcceptAt(now time.Time) bool { return sc.acceptAt(now) }
+
+// WindowStart returns when the counter's current window started.
+func (sc *SlidingWindowCounter) WindowStart() time.Time {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.windowStart
+}
+
+// Get and AcceptWith split KeyedLimiter.Accept in two, so tests can evict
+// a key between looking up its limiter and using it.
+func (kl *KeyedLimiter) Get(key string) (*FixedWindow, error) { return kl.get(key) }
+
+func (kl *KeyedLimiter) AcceptWith(fw *FixedWindow, n uint64) bool {
+	ok, _ := kl.acceptWith(fw, n)
+	return ok
+}
+
+func (al *AdaptiveLimiter) AcceptAt(now time.Time) bool { return al.acceptAt(now) }
+
+// EffectiveLimitAt is EffectiveLimit as if the current time were now.
+func (al *AdaptiveLimiter) EffectiveLimitAt(now time.Time) uint64 {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.advanceLocked(now)
+	return al.limit
+}
+
+// WindowStart returns when the current window started.
+func (al *AdaptiveLimiter) WindowStart() time.Time {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	return al.windowStart
+}
+
+// SetJitter makes Jitter use f instead of random offsets until the returned
+// function is called.
+func SetJitter(f func(n int64) int64) (restore func()) {
+	old := jitterN
+	jitterN = f
+	return func() { jitterN = old }
+}
@@ -0,0 +1,114 @@
+package ratelimit_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// FuzzValidate checks that Validate and UnmarshalJSON never panic, and that
+// any configuration they accept starts a limiter with a usable window.
+func FuzzValidate(f *testing.F) {
+	for _, seed := range []struct {
+		duration uint64
+		unit     string
+		limit    uint64
+		window   int64
+	}{
+		{1, "second", 10, 0},
+		{1, "minute", 100, 0},
+		{24, "hour", 1, 0},
+		{1, "day", 1000, 0},
+		{500, "millisecond", 5, 0},
+		{0, "minute", 1, 0},
+		{1, "", 1, 0},
+		{1, "fortnight", 1, 0},
+		{1, "Minute", 1, 0},
+		{1, "second", 0, 0},
+		{1 << 63, "day", 1, 0},
+		{106752, "day", 1, 0},
+		{0, "", 1, int64(time.Minute)},
+		{0, "", 1, -1},
+		{0, "", 1 << 63, int64(time.Nanosecond)},
+	} {
+		f.Add(seed.duration, seed.unit, seed.limit, seed.window)
+	}
+	f.Fuzz(func(t *testing.T, duration uint64, unit string, limit uint64, window int64) {
+		fw := &ratelimit.FixedWindow{Duration: duration, Unit: unit, Limit: limit, Window: time.Duration(window)}
+		if fw.Validate() == nil {
+			checkStarts(t, fw)
+		}
+
+		cfg := map[string]any{"duration": duration, "unit": unit, "limit": limit}
+		if window != 0 {
+			cfg["window"] = time.Duration(window).String()
+		}
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded ratelimit.FixedWindow
+		if decoded.UnmarshalJSON(data) == nil {
+			checkStarts(t, &decoded)
+		}
+	})
+}
+
+// FuzzFromEnv checks that FromEnv never panics, and that any limiter it
+// returns starts with a usable window.
+func FuzzFromEnv(f *testing.F) {
+	for _, seed := range [][3]string{
+		{"100", "1", "minute"},
+		{"5", "", "second"},
+		{"1", "500", "millisecond"},
+		{"", "1", "second"},
+		{"10", "1", ""},
+		{"ten", "1", "second"},
+		{"10", "0", "second"},
+		{"10", "-1", "second"},
+		{"10", "1", "fortnight"},
+		{"0", "1", "second"},
+		{"18446744073709551616", "1", "second"},
+		{"1", "18446744073709551615", "day"},
+		{" 10", "1", "second"},
+	} {
+		f.Add(seed[0], seed[1], seed[2])
+	}
+	f.Fuzz(func(t *testing.T, limit, duration, unit string) {
+		for _, v := range []string{limit, duration, unit} {
+			if strings.ContainsRune(v, 0) {
+				t.Skip("environment variables cannot hold NUL")
+			}
+		}
+		t.Setenv("FUZZ_LIMIT", limit)
+		t.Setenv("FUZZ_DURATION", duration)
+		t.Setenv("FUZZ_UNIT", unit)
+		fw, err := ratelimit.FromEnv("FUZZ")
+		if err != nil {
+			if fw != nil {
+				t.Fatalf("got a limiter along with %v", err)
+			}
+			return
+		}
+		checkStarts(t, fw)
+	})
+}
+
+// checkStarts fails t unless fw, which passed validation, starts with a
+// positive window and its full limit available.
+func checkStarts(t *testing.T, fw *ratelimit.FixedWindow) {
+	t.Helper()
+	if err := fw.Do(); err != nil {
+		t.Fatalf("Do failed after Validate passed for %+v: %v", fw, err)
+	}
+	defer fw.Stop()
+	if d := fw.ResetIn(); d <= 0 {
+		t.Fatalf("window of %v for %v", d, fw)
+	}
+	if fw.Remaining() != fw.Limit {
+		t.Fatalf("remaining %d, want %d for %v", fw.Remaining(), fw.Limit, fw)
+	}
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// GCRA is a generic cell rate algorithm limiter. It allows one request per
+// EmissionInterval on average, and up to BurstTolerance/EmissionInterval
+// extra requests in a burst.
+//
+// The only state is the theoretical arrival time (TAT) of the next request:
+// a request is rejected if the TAT is more than BurstTolerance in the
+// future, and otherwise pushes the TAT back by one EmissionInterval.
+type GCRA struct {
+	EmissionInterval time.Duration
+	BurstTolerance   time.Duration
+
+	tat  time.Time
+	mu   *sync.Mutex
+	stop bool
+}
+
+// Validate checks that the interval and tolerance are usable.
+func (g *GCRA) Validate() error {
+	if g.EmissionInterval <= 0 {
+		return errors.New("emission interval must be greater than zero")
+	}
+	if g.BurstTolerance < 0 {
+		return errors.New("burst tolerance must not be negative")
+	}
+	return nil
+}
+
+// Do validates the configuration and prepares the limiter for use.
+func (g *GCRA) Do() error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	if g.mu == nil {
+		g.mu = &sync.Mutex{}
+	}
+	return nil
+}
+
+// Accept reports whether the request conforms to the configured rate.
+func (g *GCRA) Accept() bool {
+	return g.acceptAt(time.Now())
+}
+
+// Refund moves the theoretical arrival time back by n emission intervals,
+// but never into the past.
+func (g *GCRA) Refund(n uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	g.tat = g.tat.Add(-time.Duration(n) * g.EmissionInterval)
+	if g.tat.Before(now) {
+		g.tat = now
+	}
+}
+
+// Stop makes Accept reject every request. It may be called before Do.
+func (g *GCRA) Stop() {
+	if g.mu == nil {
+		g.stop = true
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stop = true
+}
+
+func (g *GCRA) acceptAt(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stop {
+		return false
+	}
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	if tat.Sub(now) > g.BurstTolerance {
+		return false
+	}
+	g.tat = tat.Add(g.EmissionInterval)
+	return true
+}
@@ -0,0 +1,55 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestGCRASchedule(t *testing.T) {
+	// One request per 10ms, with bursts of up to 2 extra.
+	g := &ratelimit.GCRA{EmissionInterval: 10 * time.Millisecond, BurstTolerance: 20 * time.Millisecond}
+	if err := g.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Stop()
+	var _ ratelimit.RateLimiter = g
+
+	start := time.Now()
+	for i, tc := range []struct {
+		at   time.Duration // since start, in milliseconds
+		want bool
+	}{
+		// A burst of 3 uses up the tolerance: TAT is now 30.
+		{0, true}, {0, true}, {0, true}, {0, false},
+		// At 10 the TAT is 20 ahead, which is just within the tolerance.
+		{10, true}, {10, false},
+		{15, false},
+		// At 20 one interval has freed up again.
+		{20, true}, {20, false},
+		// After a long gap the TAT is in the past and a full burst is
+		// available.
+		{1000, true}, {1000, true}, {1000, true}, {1000, false},
+		// Requests spaced at the emission interval all conform and leave
+		// the burst tolerance unused.
+		{1030, true}, {1040, true}, {1050, true},
+		{1050, true}, {1050, true}, {1050, false},
+	} {
+		if got := g.AcceptAt(start.Add(tc.at * time.Millisecond)); got != tc.want {
+			t.Fatalf("request %d at %dms: accepted %v, want %v", i, tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestGCRAValidate(t *testing.T) {
+	for _, g := range []*ratelimit.GCRA{
+		{},
+		{EmissionInterval: -time.Second},
+		{EmissionInterval: time.Second, BurstTolerance: -time.Second},
+	} {
+		if err := g.Validate(); err == nil {
+			t.Errorf("Validate accepted %+v", g)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Package ginlimit provides Gin middleware for ratelimit limiters. It has
+// its own go.mod, so Gin is only required by programs that import it.
+package ginlimit
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/govi230/ratelimit"
+)
+
+// GinMiddleware returns middleware that calls rl.Accept for every request
+// and aborts with 429 and a JSON error body when it is rejected.
+func GinMiddleware(rl ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rl.Accept() {
+			abort(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// GinMiddlewareKeyed is like GinMiddleware but keeps a separate limit for
+// every key returned by keyFunc, such as the route or the client. A nil
+// keyFunc keys requests by c.ClientIP().
+func GinMiddlewareKeyed(kl *ratelimit.KeyedLimiter, keyFunc func(*gin.Context) string) gin.HandlerFunc {
+	if keyFunc == nil {
+		keyFunc = (*gin.Context).ClientIP
+	}
+	return func(c *gin.Context) {
+		if !kl.Accept(keyFunc(c)) {
+			abort(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func abort(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+}
@@ -0,0 +1,67 @@
+package ginlimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/govi230/ratelimit"
+	"github.com/govi230/ratelimit/ginlimit"
+)
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fw := ratelimit.NewFixedWindow(time.Hour, 1)
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+	r := gin.New()
+	r.Use(ginlimit.GinMiddleware(fw))
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	for i, want := range []struct {
+		code int
+		body string
+	}{
+		{http.StatusOK, "ok"},
+		{http.StatusTooManyRequests, `{"error":"rate limit exceeded"}`},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != want.code || rec.Body.String() != want.body {
+			t.Fatalf("request %d: got %d %q, want %d %q", i, rec.Code, rec.Body, want.code, want.body)
+		}
+	}
+}
+
+func TestGinMiddlewareKeyed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	kl := &ratelimit.KeyedLimiter{Window: time.Hour, Limit: 1}
+	if err := kl.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer kl.Stop()
+	r := gin.New()
+	r.Use(ginlimit.GinMiddlewareKeyed(kl, nil))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i, want := range []struct {
+		addr string
+		code int
+	}{
+		{"192.0.2.1:1234", http.StatusOK},
+		{"192.0.2.1:5678", http.StatusTooManyRequests},
+		{"192.0.2.2:1234", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = want.addr
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != want.code {
+			t.Fatalf("request %d from %s: got %d, want %d", i, want.addr, rec.Code, want.code)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/govi230/ratelimit
+
+go 1.25.0
@@ -0,0 +1,34 @@
+// Package grpclimit provides gRPC interceptors for ratelimit limiters. It
+// is a separate module, keeping gRPC out of the ratelimit module's
+// requirements.
+package grpclimit
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/govi230/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns an interceptor that calls rl.Accept for
+// every unary call and fails it with codes.ResourceExhausted when rejected.
+//
+// If rl, or a limiter it wraps, implements ratelimit.WindowReporter, the
+// remaining count is sent in the x-ratelimit-remaining trailer.
+func UnaryServerInterceptor(rl ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ok := rl.Accept()
+		if wr, isReporter := ratelimit.As[ratelimit.WindowReporter](rl); isReporter {
+			remaining := strconv.FormatUint(wr.Remaining(), 10)
+			_ = grpc.SetTrailer(ctx, metadata.Pairs("x-ratelimit-remaining", remaining))
+		}
+		if !ok {
+			return nil, status.Errorf(codes.ResourceExhausted, "%s is rate limited", info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,63 @@
+package grpclimit_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+	"github.com/govi230/ratelimit/grpclimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	fw := ratelimit.NewFixedWindow(time.Hour, 2)
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.UnaryInterceptor(grpclimit.UnaryServerInterceptor(fw)))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	for i, want := range []struct {
+		code      codes.Code
+		remaining string
+	}{
+		{codes.OK, "1"},
+		{codes.OK, "0"},
+		{codes.ResourceExhausted, "0"},
+	} {
+		var trailer metadata.MD
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer))
+		if got := status.Code(err); got != want.code {
+			t.Fatalf("call %d: code %v, want %v (%v)", i, got, want.code, err)
+		}
+		if got := trailer.Get("x-ratelimit-remaining"); len(got) != 1 || got[0] != want.remaining {
+			t.Fatalf("call %d: x-ratelimit-remaining %q, want %q", i, got, want.remaining)
+		}
+	}
+}
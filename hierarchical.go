@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Hierarchical enforces nested keyed limits, such as a global limit, then
+// one per tenant, then one per user. Accept is given one key per layer,
+// outermost first, and succeeds only if every layer accepts its key; if a
+// layer rejects, the layers above it are refunded, so a rejected request
+// uses up none of the limits.
+//
+// A layer that applies to everything, like a global limit, is a
+// KeyedLimiter that is always given the same key.
+type Hierarchical struct {
+	Layers []*KeyedLimiter
+}
+
+// Validate checks every layer.
+func (h *Hierarchical) Validate() error {
+	if len(h.Layers) == 0 {
+		return errors.New("at least one layer is required")
+	}
+	for i, kl := range h.Layers {
+		if err := kl.Validate(); err != nil {
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Do validates the configuration and starts every layer. If one fails to
+// start, the ones already started are stopped again.
+func (h *Hierarchical) Do() error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+	for i, kl := range h.Layers {
+		if err := kl.Do(); err != nil {
+			for _, started := range h.Layers[:i] {
+				started.Stop()
+			}
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Accept reports whether every layer accepts its key, keys[i] being the key
+// for Layers[i]. It rejects if the number of keys does not match the number
+// of layers.
+//
+// The layers are not locked together, so a concurrent request may briefly
+// see units that are about to be refunded and be rejected too. Units are
+// never overcommitted.
+func (h *Hierarchical) Accept(keys ...string) bool {
+	if len(keys) != len(h.Layers) {
+		return false
+	}
+	for i, kl := range h.Layers {
+		if !kl.Accept(keys[i]) {
+			for j, accepted := range h.Layers[:i] {
+				accepted.Refund(keys[j], 1)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Stop stops every layer.
+func (h *Hierarchical) Stop() {
+	for _, kl := range h.Layers {
+		kl.Stop()
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestHierarchicalRejectsWithoutCharging(t *testing.T) {
+	global := &ratelimit.KeyedLimiter{Window: time.Hour, Limit: 10}
+	tenant := &ratelimit.KeyedLimiter{Window: time.Hour, Limit: 5}
+	user := &ratelimit.KeyedLimiter{Window: time.Hour, Limit: 2}
+	h := &ratelimit.Hierarchical{Layers: []*ratelimit.KeyedLimiter{global, tenant, user}}
+	if err := h.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+	for i, want := range []bool{true, true, false, false} {
+		if got := h.Accept("all", "acme", "alice"); got != want {
+			t.Fatalf("alice's request %d: got %v, want %v", i, got, want)
+		}
+	}
+	// The user layer rejected alice's last two requests after the global
+	// and tenant layers had accepted them, and both were refunded: another
+	// user of the same tenant still has three of the tenant's five.
+	for i, want := range []bool{true, true, false} {
+		if got := h.Accept("all", "acme", "bob"); got != want {
+			t.Fatalf("bob's request %d: got %v, want %v", i, got, want)
+		}
+	}
+	if !h.Accept("all", "acme", "carol") {
+		t.Fatal("the tenant's fifth request rejected: it was charged for rejected ones")
+	}
+	if h.Accept("all", "acme", "dave") {
+		t.Fatal("the tenant's sixth request accepted")
+	}
+	fw, err := global.Get("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fw.Remaining(); got != 5 {
+		t.Fatalf("global remaining %d, want 5", got)
+	}
+}
+
+func TestHierarchicalKeyCount(t *testing.T) {
+	h := &ratelimit.Hierarchical{Layers: []*ratelimit.KeyedLimiter{
+		{Window: time.Hour, Limit: 1},
+		{Window: time.Hour, Limit: 1},
+	}}
+	if err := h.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+	if h.Accept("only one") {
+		t.Fatal("accepted with too few keys")
+	}
+	if !h.Accept("all", "acme") {
+		t.Fatal("rejected a request with a key per layer")
+	}
+}
@@ -0,0 +1,384 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WindowReporter is implemented by limiters that can describe their current
+// window. HTTPMiddleware uses it to set the X-RateLimit-* headers; limiters
+// that do not implement it are still enforced, just without the headers.
+type WindowReporter interface {
+	// WindowLimit returns the number of requests allowed per window.
+	WindowLimit() uint64
+	// Remaining returns how many more requests fit in the current window.
+	Remaining() uint64
+	// ResetIn returns the time left until the current window ends.
+	ResetIn() time.Duration
+}
+
+// RetryReporter is implemented by limiters that can say how long until a
+// request would be accepted. HTTPMiddleware uses it for the Retry-After
+// header; without it, Retry-After is the time until the window resets.
+type RetryReporter interface {
+	RetryAfter() time.Duration
+}
+
+// MiddlewareOption configures HTTPMiddleware and its variants.
+type MiddlewareOption func(*middleware)
+
+// WithCostFunc makes each request cost cost(r) units instead of one, so
+// expensive endpoints use up the limit faster. The limiter must implement
+// AcceptN for costs other than one to take effect; otherwise every request
+// is a single Accept.
+func WithCostFunc(cost func(*http.Request) uint64) MiddlewareOption {
+	return func(m *middleware) { m.cost = cost }
+}
+
+// WithRejectStatus sets the status code of rejected requests. It defaults to
+// 429 Too Many Requests.
+func WithRejectStatus(code int) MiddlewareOption {
+	return func(m *middleware) { m.status = code }
+}
+
+// WithRejectHandler makes rejected requests be answered by h instead of the
+// default plain-text response, e.g. to send JSON or redirect. Retry-After
+// and the X-RateLimit-* headers are already set when h is called.
+func WithRejectHandler(h http.HandlerFunc) MiddlewareOption {
+	return func(m *middleware) { m.rejectHandler = h }
+}
+
+// RetryAfterFormat selects how the Retry-After header of rejected requests
+// is written.
+type RetryAfterFormat int
+
+const (
+	// RetryAfterSeconds writes the number of seconds to wait, rounded up,
+	// e.g. "Retry-After: 3". It is the default.
+	RetryAfterSeconds RetryAfterFormat = iota
+	// RetryAfterHTTPDate writes the time at which to retry, rounded up to
+	// the next whole second, e.g. "Retry-After: Wed, 21 Oct 2015 07:28:00
+	// GMT".
+	RetryAfterHTTPDate
+)
+
+// WithRetryAfterFormat sets the format of the Retry-After header.
+func WithRetryAfterFormat(f RetryAfterFormat) MiddlewareOption {
+	return func(m *middleware) { m.retryAfterFormat = f }
+}
+
+// AcceptNer is implemented by limiters that can accept several units at once.
+type AcceptNer interface {
+	AcceptN(n uint64) bool
+}
+
+// ReasonAccepter is implemented by limiters that can say why a request was
+// rejected. HTTPMiddleware uses it to answer 503 Service Unavailable instead
+// of 429 when the limiter has been stopped. Unlike WindowReporter it is not
+// looked up through a Wrapper, so wrappers implement it themselves.
+type ReasonAccepter interface {
+	AcceptWithReason() (bool, Reason)
+}
+
+// ContextAccepter is implemented by limiters that decide with the context
+// of the request, such as otellimit.Limiter, which records the decision on
+// the request's trace span. HTTPMiddleware prefers it to ReasonAccepter.
+// Like ReasonAccepter, it is not looked up through a Wrapper.
+type ContextAccepter interface {
+	AcceptContextWithReason(ctx context.Context) (bool, Reason)
+}
+
+type reasonAccepterN interface {
+	AcceptNWithReason(n uint64) (bool, Reason)
+}
+
+type middleware struct {
+	cost             func(*http.Request) uint64
+	status           int
+	rejectHandler    http.HandlerFunc
+	retryAfterFormat RetryAfterFormat
+}
+
+func newMiddleware(opts []MiddlewareOption) *middleware {
+	m := &middleware{status: http.StatusTooManyRequests}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// serve applies rl to r and either rejects it or passes it on to next.
+func (m *middleware) serve(w http.ResponseWriter, r *http.Request, next http.Handler, rl Limiter) {
+	ok, reason := m.accept(r, rl)
+	if reason == ReasonStopped {
+		unavailable(w)
+		return
+	}
+	retryAfter := setRateLimitHeaders(w, rl)
+	if !ok {
+		// RetryAfter also accounts for MinInterval, so prefer it to the
+		// time until the window resets.
+		if ra, isRA := As[RetryReporter](rl); isRA {
+			retryAfter = ra.RetryAfter()
+		}
+		m.reject(w, r, retryAfter)
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+// accept counts r against rl. The reason is only meaningful if rl can
+// report one; otherwise it is ReasonLimitReached for every rejection.
+func (m *middleware) accept(r *http.Request, rl Limiter) (bool, Reason) {
+	if m.cost != nil {
+		switch an := rl.(type) {
+		case reasonAccepterN:
+			return an.AcceptNWithReason(m.cost(r))
+		case AcceptNer:
+			return withReason(an.AcceptN(m.cost(r)))
+		}
+	}
+	if ca, ok := rl.(ContextAccepter); ok {
+		return ca.AcceptContextWithReason(r.Context())
+	}
+	return AcceptWithReason(rl)
+}
+
+func withReason(ok bool) (bool, Reason) {
+	if ok {
+		return true, ReasonOK
+	}
+	return false, ReasonLimitReached
+}
+
+// HTTPMiddleware returns net/http middleware that calls rl.Accept for every
+// request and answers with 429 Too Many Requests when it is rejected, or
+// with 503 Service Unavailable if rl implements ReasonAccepter and has been
+// stopped.
+//
+// If rl implements WindowReporter, every response carries
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset, the last
+// being the number of seconds until the window resets.
+func HTTPMiddleware(rl Limiter, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := newMiddleware(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serve(w, r, next, rl)
+		})
+	}
+}
+
+// HTTPMiddlewareKeyed is like HTTPMiddleware but keeps a separate limit for
+// every key returned by keyFunc. A nil keyFunc keys requests by RemoteIP.
+func HTTPMiddlewareKeyed(kl *KeyedLimiter, keyFunc func(*http.Request) string, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return keyedMiddleware(func(*http.Request) *KeyedLimiter { return kl }, keyFunc, opts)
+}
+
+// Tier is the class of client a request comes from, for
+// HTTPMiddlewareTiered.
+type Tier int
+
+const (
+	// TierAnonymous is for requests that carry no credentials.
+	TierAnonymous Tier = iota
+	// TierAuthenticated is for requests from known clients.
+	TierAuthenticated
+)
+
+// HTTPMiddlewareTiered is like HTTPMiddlewareKeyed but with two tiers of
+// limits, picked for each request by tierFunc: typically a looser
+// authenticated limiter and a stricter anonymous one. Keys are only
+// compared within a tier. A nil keyFunc keys requests by RemoteIP.
+func HTTPMiddlewareTiered(anonymous, authenticated *KeyedLimiter, tierFunc func(*http.Request) Tier, keyFunc func(*http.Request) string, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return keyedMiddleware(func(r *http.Request) *KeyedLimiter {
+		if tierFunc(r) == TierAuthenticated {
+			return authenticated
+		}
+		return anonymous
+	}, keyFunc, opts)
+}
+
+// keyedMiddleware limits each request with the KeyedLimiter chosen by pick,
+// under the key returned by keyFunc.
+func keyedMiddleware(pick func(*http.Request) *KeyedLimiter, keyFunc func(*http.Request) string, opts []MiddlewareOption) func(http.Handler) http.Handler {
+	if keyFunc == nil {
+		keyFunc = RemoteIP
+	}
+	m := newMiddleware(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kl := pick(r)
+			fw, err := kl.get(keyFunc(r))
+			if errors.Is(err, ErrStopped) {
+				unavailable(w)
+				return
+			}
+			if err != nil {
+				m.reject(w, r, 0)
+				return
+			}
+			m.serve(w, r, next, keyLimiter{kl: kl, FixedWindow: fw})
+		})
+	}
+}
+
+// keyLimiter is the limiter of one key of a KeyedLimiter. It reports the
+// key's window but also counts against GlobalLimit.
+type keyLimiter struct {
+	kl *KeyedLimiter
+	*FixedWindow
+}
+
+func (k keyLimiter) Accept() bool {
+	ok, _ := k.AcceptNWithReason(1)
+	return ok
+}
+
+func (k keyLimiter) AcceptWithReason() (bool, Reason) {
+	return k.AcceptNWithReason(1)
+}
+
+func (k keyLimiter) AcceptN(n uint64) bool {
+	ok, _ := k.AcceptNWithReason(n)
+	return ok
+}
+
+func (k keyLimiter) AcceptNWithReason(n uint64) (bool, Reason) {
+	return k.kl.acceptWith(k.FixedWindow, n)
+}
+
+// HTTPMiddlewareByHeader is like HTTPMiddlewareKeyed but keys requests by
+// the value of the named header, e.g. "X-API-Key" or "Authorization".
+// Requests without the header, or with only whitespace in it, all share a
+// single limit; use HTTPMiddlewareKeyed with a custom keyFunc to treat them
+// differently, for instance by falling back to RemoteIP.
+func HTTPMiddlewareByHeader(kl *KeyedLimiter, header string, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return HTTPMiddlewareKeyed(kl, func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(header))
+	}, opts...)
+}
+
+// HTTPMiddlewareByMethod is like HTTPMiddleware but picks the limiter by
+// request method, e.g. a stricter one for "POST" than for "GET". The
+// limiter under the empty key, if any, applies to methods not in limits;
+// without one, such requests pass through unlimited. The map is copied, so
+// later changes to it have no effect.
+func HTTPMiddlewareByMethod(limits map[string]RateLimiter, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	byMethod := make(map[string]RateLimiter, len(limits))
+	for method, rl := range limits {
+		byMethod[method] = rl
+	}
+	m := newMiddleware(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rl, ok := byMethod[r.Method]
+			if !ok {
+				rl, ok = byMethod[""]
+			}
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			m.serve(w, r, next, rl)
+		})
+	}
+}
+
+// RemoteIP returns the client IP from r.RemoteAddr, without the port. IPv6
+// addresses are returned without brackets or zone.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return normalizeIP(host)
+}
+
+// ForwardedIP returns the client IP recorded by the proxy in front of the
+// server: the last address in the X-Forwarded-For header, falling back to
+// RemoteIP if the header is missing or its last entry is not an address.
+//
+// Each proxy appends the address it received the request from, so only the
+// last entry comes from the proxy; earlier ones are whatever the client sent.
+// Behind several proxies the last entry is the next proxy out, so use a
+// keyFunc that skips the known proxies instead. Only use ForwardedIP behind a
+// proxy that sets the header, since clients can send any value they like.
+func ForwardedIP(r *http.Request) string {
+	values := r.Header.Values("X-Forwarded-For")
+	if len(values) == 0 {
+		return RemoteIP(r)
+	}
+	last := values[len(values)-1]
+	if i := strings.LastIndexByte(last, ','); i >= 0 {
+		last = last[i+1:]
+	}
+	last = strings.TrimSpace(last)
+	if addr, err := netip.ParseAddr(strings.Trim(last, "[]")); err == nil {
+		return addr.WithZone("").Unmap().String()
+	}
+	// Some proxies include the port, e.g. "[2001:db8::1]:443".
+	if ap, err := netip.ParseAddrPort(last); err == nil {
+		return ap.Addr().WithZone("").Unmap().String()
+	}
+	return RemoteIP(r)
+}
+
+func normalizeIP(host string) string {
+	addr, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	if err != nil {
+		return host
+	}
+	return addr.WithZone("").Unmap().String()
+}
+
+// setRateLimitHeaders sets the X-RateLimit-* headers if rl can report them
+// and returns the time until its window resets, or zero if unknown.
+func setRateLimitHeaders(w http.ResponseWriter, rl Limiter) time.Duration {
+	wr, ok := As[WindowReporter](rl)
+	if !ok {
+		return 0
+	}
+	resetIn := wr.ResetIn()
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.FormatUint(wr.WindowLimit(), 10))
+	h.Set("X-RateLimit-Remaining", strconv.FormatUint(wr.Remaining(), 10))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(resetIn), 10))
+	return resetIn
+}
+
+func (m *middleware) reject(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	// Without a known reset time ask the client to back off for a second.
+	retryAfter = max(retryAfter, time.Second)
+	if m.retryAfterFormat == RetryAfterHTTPDate {
+		at := time.Now().Add(retryAfter)
+		if t := at.Truncate(time.Second); t.Before(at) {
+			at = t.Add(time.Second)
+		}
+		w.Header().Set("Retry-After", at.UTC().Format(http.TimeFormat))
+	} else {
+		w.Header().Set("Retry-After", strconv.FormatInt(ceilSeconds(retryAfter), 10))
+	}
+	if m.rejectHandler != nil {
+		m.rejectHandler(w, r)
+		return
+	}
+	http.Error(w, http.StatusText(m.status), m.status)
+}
+
+func unavailable(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,446 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+// serve sends a GET for path through h and returns the response.
+func serve(h http.Handler, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHTTPMiddlewareOverflow(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 3, Clock: clock})
+	h := ratelimit.HTTPMiddleware(fw)(okHandler)
+	for i := range 3 {
+		if rec := serve(h, "/"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i, rec.Code)
+		}
+	}
+	clock.Add(20 * time.Second)
+	for i := range 2 {
+		rec := serve(h, "/")
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("overflow request %d: status %d, want 429", i, rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "40" {
+			t.Fatalf("overflow request %d: Retry-After %q, want 40", i, got)
+		}
+	}
+	clock.Add(40 * time.Second)
+	if rec := serve(h, "/"); rec.Code != http.StatusOK {
+		t.Fatalf("status %d after the window reset, want 200", rec.Code)
+	}
+}
+
+func TestHTTPMiddlewareHeaders(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 3, Clock: clock})
+	h := ratelimit.HTTPMiddleware(fw)(okHandler)
+	for i, want := range []struct{ remaining, reset string }{
+		{"2", "60"},
+		{"1", "50"},
+		{"0", "40"},
+		{"0", "30"},
+	} {
+		rec := serve(h, "/")
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Fatalf("request %d: X-RateLimit-Limit %q, want 3", i, got)
+		}
+		if got := rec.Header().Get("X-RateLimit-Remaining"); got != want.remaining {
+			t.Fatalf("request %d: X-RateLimit-Remaining %q, want %s", i, got, want.remaining)
+		}
+		if got := rec.Header().Get("X-RateLimit-Reset"); got != want.reset {
+			t.Fatalf("request %d: X-RateLimit-Reset %q, want %s", i, got, want.reset)
+		}
+		clock.Add(10 * time.Second)
+	}
+}
+
+func TestHTTPMiddlewareWithoutWindowReporter(t *testing.T) {
+	rec := serve(ratelimit.HTTPMiddleware(ratelimit.NoOp{})(okHandler), "/")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", rec.Code)
+	}
+	for _, name := range []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"} {
+		if v := rec.Header().Get(name); v != "" {
+			t.Fatalf("%s set to %q for a limiter that cannot report it", name, v)
+		}
+	}
+}
+
+func TestHTTPMiddlewareKeyedByIP(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Duration: 1, Unit: "minute", Limit: 1})
+	h := ratelimit.HTTPMiddlewareKeyed(kl, nil)(okHandler)
+	from := func(addr string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for i, tc := range []struct {
+		addr string
+		want int
+	}{
+		{"192.0.2.1:1234", http.StatusOK},
+		{"192.0.2.2:1234", http.StatusOK},
+		// A new port is the same client.
+		{"192.0.2.1:5678", http.StatusTooManyRequests},
+		{"[2001:db8::1]:443", http.StatusOK},
+		{"[2001:db8::1%eth0]:444", http.StatusTooManyRequests},
+		// IPv4-mapped IPv6 is the IPv4 client.
+		{"[::ffff:192.0.2.2]:80", http.StatusTooManyRequests},
+	} {
+		if got := from(tc.addr); got != tc.want {
+			t.Fatalf("request %d from %s: status %d, want %d", i, tc.addr, got, tc.want)
+		}
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	for addr, want := range map[string]string{
+		"192.0.2.1:1234":         "192.0.2.1",
+		"[2001:db8::1]:443":      "2001:db8::1",
+		"[fe80::1%eth0]:443":     "fe80::1",
+		"[::ffff:192.0.2.1]:443": "192.0.2.1",
+		"192.0.2.1":              "192.0.2.1",
+		"2001:db8::1":            "2001:db8::1",
+		"pipe":                   "pipe",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		if got := ratelimit.RemoteIP(req); got != want {
+			t.Errorf("RemoteIP for %q = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestForwardedIP(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header []string
+		want   string
+	}{
+		{"no header", nil, "203.0.113.9"},
+		{"single", []string{"192.0.2.1"}, "192.0.2.1"},
+		{"last of several", []string{"198.51.100.7, 192.0.2.1"}, "192.0.2.1"},
+		{"spoofed first entry", []string{"10.0.0.1, 198.51.100.7,192.0.2.1"}, "192.0.2.1"},
+		{"last of several headers", []string{"10.0.0.1", "192.0.2.1"}, "192.0.2.1"},
+		{"IPv6", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"bracketed IPv6 with port", []string{"192.0.2.1, [2001:db8::1]:443"}, "2001:db8::1"},
+		{"IPv4 with port", []string{"192.0.2.1:8080"}, "192.0.2.1"},
+		{"IPv4-mapped", []string{"::ffff:192.0.2.1"}, "192.0.2.1"},
+		{"zone", []string{"fe80::1%eth0"}, "fe80::1"},
+		// An invalid last entry is not skipped in favour of an earlier,
+		// client-controlled one.
+		{"invalid last entry", []string{"192.0.2.1, unknown"}, "203.0.113.9"},
+		{"empty last entry", []string{"192.0.2.1,"}, "203.0.113.9"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.9:4321"
+			for _, v := range tc.header {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if got := ratelimit.ForwardedIP(req); got != tc.want {
+				t.Fatalf("ForwardedIP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareCost(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 10))
+	cost := func(r *http.Request) uint64 {
+		if r.URL.Path == "/export" {
+			return 5
+		}
+		return 1
+	}
+	h := ratelimit.HTTPMiddleware(fw, ratelimit.WithCostFunc(cost))(okHandler)
+	for i, want := range []struct {
+		path string
+		code int
+	}{
+		{"/", http.StatusOK},
+		{"/export", http.StatusOK},
+		// 6 of 10 used: another export would go over.
+		{"/export", http.StatusTooManyRequests},
+		{"/", http.StatusOK},
+		{"/", http.StatusOK},
+		{"/", http.StatusOK},
+		{"/", http.StatusOK},
+		{"/", http.StatusTooManyRequests},
+	} {
+		if rec := serve(h, want.path); rec.Code != want.code {
+			t.Fatalf("request %d to %s: status %d, want %d", i, want.path, rec.Code, want.code)
+		}
+	}
+}
+
+func TestHTTPMiddlewareStopped(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	h := ratelimit.HTTPMiddleware(fw)(okHandler)
+	serve(h, "/")
+	if rec := serve(h, "/"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("full window: status %d, want 429", rec.Code)
+	}
+	fw.Stop()
+	rec := serve(h, "/")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("stopped: status %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "" {
+		t.Fatalf("stopped: Retry-After %q, want none", got)
+	}
+}
+
+func TestHTTPMiddlewareRejectOptions(t *testing.T) {
+	full := func() ratelimit.Limiter {
+		fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+		fw.Accept()
+		return fw
+	}
+
+	rec := serve(ratelimit.HTTPMiddleware(full())(okHandler), "/")
+	if rec.Code != http.StatusTooManyRequests || rec.Body.String() != "Too Many Requests\n" {
+		t.Fatalf("default: got %d %q, want 429 plain text", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Fatalf("default: Content-Type %q", got)
+	}
+
+	rec = serve(ratelimit.HTTPMiddleware(full(), ratelimit.WithRejectStatus(http.StatusServiceUnavailable))(okHandler), "/")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("WithRejectStatus: status %d, want 503", rec.Code)
+	}
+
+	called := 0
+	custom := func(w http.ResponseWriter, r *http.Request) {
+		called++
+		if w.Header().Get("Retry-After") == "" {
+			t.Error("Retry-After not set before the reject handler")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"slow down"}`))
+	}
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	h := ratelimit.HTTPMiddleware(fw, ratelimit.WithRejectHandler(custom))(okHandler)
+	if rec := serve(h, "/"); rec.Code != http.StatusOK || called != 0 {
+		t.Fatalf("accepted: status %d, reject handler called %d times", rec.Code, called)
+	}
+	rec = serve(h, "/")
+	if called != 1 || rec.Body.String() != `{"error":"slow down"}` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("rejected: handler called %d times, body %q", called, rec.Body)
+	}
+}
+
+func TestHTTPMiddlewareByHeader(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Window: time.Hour, Limit: 1})
+	h := ratelimit.HTTPMiddlewareByHeader(kl, "X-API-Key")(okHandler)
+	for i, want := range []struct {
+		key  string
+		code int
+	}{
+		{"alpha", http.StatusOK},
+		{"beta", http.StatusOK},
+		{"alpha", http.StatusTooManyRequests},
+		{" beta ", http.StatusTooManyRequests},
+		// Requests without a key share one limit.
+		{"", http.StatusOK},
+		{"   ", http.StatusTooManyRequests},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if want.key != "" {
+			req.Header.Set("X-API-Key", want.key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want.code {
+			t.Fatalf("request %d with key %q: status %d, want %d", i, want.key, rec.Code, want.code)
+		}
+	}
+}
+
+func TestHTTPMiddlewareRetryAfterFormats(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 1, Clock: clock})
+	fw.Accept()
+	// 40.5s are left in the window.
+	clock.Add(19500 * time.Millisecond)
+
+	if got := serve(ratelimit.HTTPMiddleware(fw)(okHandler), "/").Header().Get("Retry-After"); got != "41" {
+		t.Fatalf("seconds: Retry-After %q, want 41", got)
+	}
+
+	before := time.Now()
+	rec := serve(ratelimit.HTTPMiddleware(fw, ratelimit.WithRetryAfterFormat(ratelimit.RetryAfterHTTPDate))(okHandler), "/")
+	after := time.Now()
+	at, err := http.ParseTime(rec.Header().Get("Retry-After"))
+	if err != nil {
+		t.Fatalf("HTTP date: %v", err)
+	}
+	// The date is rounded up to a whole second, so it is never early.
+	earliest, latest := before.Add(40500*time.Millisecond), after.Add(41500*time.Millisecond)
+	if at.Before(earliest) || at.After(latest) {
+		t.Fatalf("HTTP date: Retry-After %v, want between %v and %v", at, earliest, latest)
+	}
+}
+
+func TestHTTPMiddlewareRetryAfterAtLeastOneSecond(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 1, Clock: clock})
+	fw.Accept()
+	clock.Add(time.Minute - time.Millisecond)
+	if got := serve(ratelimit.HTTPMiddleware(fw)(okHandler), "/").Header().Get("Retry-After"); got != "1" {
+		t.Fatalf("Retry-After %q with 1ms left, want 1", got)
+	}
+}
+
+func TestHTTPMiddlewareByMethod(t *testing.T) {
+	get := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 2})
+	post := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 1})
+	other := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 1})
+	h := ratelimit.HTTPMiddlewareByMethod(map[string]ratelimit.RateLimiter{
+		http.MethodGet:  get,
+		http.MethodPost: post,
+		"":              other,
+	})(okHandler)
+	do := func(method string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, "/", nil))
+		return rec.Code
+	}
+	for _, step := range []struct {
+		method string
+		want   int
+	}{
+		{http.MethodPost, http.StatusOK},
+		{http.MethodPost, http.StatusTooManyRequests},
+		// POST being exhausted leaves GET's budget untouched.
+		{http.MethodGet, http.StatusOK},
+		{http.MethodGet, http.StatusOK},
+		{http.MethodGet, http.StatusTooManyRequests},
+		// Unlisted methods share the limiter under the empty key.
+		{http.MethodPut, http.StatusOK},
+		{http.MethodDelete, http.StatusTooManyRequests},
+	} {
+		if got := do(step.method); got != step.want {
+			t.Fatalf("%s: status %d, want %d", step.method, got, step.want)
+		}
+	}
+
+	unlisted := ratelimit.HTTPMiddlewareByMethod(map[string]ratelimit.RateLimiter{
+		http.MethodPost: post,
+	})(okHandler)
+	for i := range 3 {
+		rec := httptest.NewRecorder()
+		unlisted.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unlisted method without a default, request %d: status %d, want 200", i, rec.Code)
+		}
+	}
+}
+
+// retryLimiter is a minimal hand-written Limiter that always rejects and
+// reports a fixed RetryAfter.
+type retryLimiter struct{}
+
+func (retryLimiter) Accept() bool              { return false }
+func (retryLimiter) RetryAfter() time.Duration { return 3 * time.Second }
+
+// contextLimiter records the context it was asked with and reports the
+// limiter as stopped once the context carries a value for its key.
+type contextLimiter struct {
+	got context.Context
+}
+
+type ctxKey struct{}
+
+func (l *contextLimiter) Accept() bool { return true }
+
+func (l *contextLimiter) AcceptContextWithReason(ctx context.Context) (bool, ratelimit.Reason) {
+	l.got = ctx
+	if ctx.Value(ctxKey{}) != nil {
+		return false, ratelimit.ReasonStopped
+	}
+	return true, ratelimit.ReasonOK
+}
+
+func TestHTTPMiddlewareContextAccepter(t *testing.T) {
+	rl := &contextLimiter{}
+	h := ratelimit.HTTPMiddleware(rl)(okHandler)
+	if rec := serve(h, "/"); rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", rec.Code)
+	}
+	ctx := context.WithValue(context.Background(), ctxKey{}, true)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if rl.got != ctx {
+		t.Fatal("limiter was not asked with the request's context")
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d for a stopped limiter, want 503", rec.Code)
+	}
+}
+
+func TestHTTPMiddlewareCustomLimiter(t *testing.T) {
+	rec := serve(ratelimit.HTTPMiddleware(retryLimiter{})(okHandler), "/")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "3" {
+		t.Fatalf("Retry-After %q, want 3", got)
+	}
+	if got := rec.Header().Get("X-RateLimit-Limit"); got != "" {
+		t.Fatalf("X-RateLimit-Limit %q from a limiter without WindowReporter", got)
+	}
+}
+
+func TestHTTPMiddlewareTiered(t *testing.T) {
+	anonymous := startKeyed(t, &ratelimit.KeyedLimiter{Window: time.Minute, Limit: 2})
+	authenticated := startKeyed(t, &ratelimit.KeyedLimiter{Window: time.Minute, Limit: 5})
+	h := ratelimit.HTTPMiddlewareTiered(anonymous, authenticated, func(r *http.Request) ratelimit.Tier {
+		if r.Header.Get("Authorization") != "" {
+			return ratelimit.TierAuthenticated
+		}
+		return ratelimit.TierAnonymous
+	}, nil)(okHandler)
+
+	// Both clients come from the same address, which is only compared
+	// within a tier.
+	accepted := func(auth string) int {
+		n := 0
+		for range 10 {
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if auth != "" {
+				r.Header.Set("Authorization", auth)
+			}
+			h.ServeHTTP(rec, r)
+			if rec.Code == http.StatusOK {
+				n++
+			}
+		}
+		return n
+	}
+	if got := accepted(""); got != 2 {
+		t.Fatalf("anonymous requests accepted %d, want 2", got)
+	}
+	if got := accepted("Bearer token"); got != 5 {
+		t.Fatalf("authenticated requests accepted %d, want 5", got)
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// fixedWindowConfig is the JSON form of a FixedWindow's configuration.
+type fixedWindowConfig struct {
+	Duration uint64 `json:"duration,omitempty"`
+	Unit     string `json:"unit,omitempty"`
+	Limit    uint64 `json:"limit"`
+	Window   string `json:"window,omitempty"`
+}
+
+// MarshalJSON encodes the limiter's configuration. Runtime state such as
+// the counter is not included. Window, if set, is encoded as a Go duration
+// string such as "1m30s".
+func (fw *FixedWindow) MarshalJSON() ([]byte, error) {
+	fw.mu.RLock()
+	c := fixedWindowConfig{
+		Duration: fw.Duration,
+		Unit:     fw.Unit,
+		Limit:    fw.Limit,
+	}
+	if fw.Window != 0 {
+		c.Window = fw.Window.String()
+	}
+	fw.mu.RUnlock()
+	return json.Marshal(c)
+}
+
+// UnmarshalJSON decodes a configuration written by MarshalJSON into a
+// limiter that has not been started, and validates it.
+func (fw *FixedWindow) UnmarshalJSON(data []byte) error {
+	var c fixedWindowConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	var window time.Duration
+	if c.Window != "" {
+		d, err := time.ParseDuration(c.Window)
+		if err != nil {
+			return err
+		}
+		window = d
+	}
+	cfg := FixedWindow{Duration: c.Duration, Unit: c.Unit, Limit: c.Limit, Window: window}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	fw.Duration, fw.Unit, fw.Limit, fw.Window = c.Duration, c.Unit, c.Limit, window
+	return nil
+}
@@ -0,0 +1,90 @@
+package ratelimit_test
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, want := range []*ratelimit.FixedWindow{
+		{Duration: 2, Unit: "minute", Limit: 100},
+		{Window: 90 * time.Second, Limit: 7},
+	} {
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got ratelimit.FixedWindow
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unmarshal %s: %v", data, err)
+		}
+		if got.Duration != want.Duration || got.Unit != want.Unit || got.Limit != want.Limit || got.Window != want.Window {
+			t.Fatalf("%s decoded to duration %d, unit %q, limit %d, window %v", data, got.Duration, got.Unit, got.Limit, got.Window)
+		}
+	}
+}
+
+func TestJSONOmitsRuntimeState(t *testing.T) {
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "hour", Limit: 5})
+	fw.Accept()
+	data, err := json.Marshal(fw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"duration":1,"unit":"hour","limit":5}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestJSONConcurrentWithSetters(t *testing.T) {
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "hour", Limit: 5})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			fw.SetLimit(uint64(i + 1))
+			fw.SetWindow(time.Duration(i+1) * time.Second)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			if _, err := json.Marshal(fw); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+func TestJSONRejectsInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"duration":1,"unit":"fortnight","limit":5}`,
+		`{"duration":1,"unit":"minute","limit":0}`,
+		`{"window":"soon","limit":5}`,
+		`{"limit":"five"}`,
+	} {
+		fw := ratelimit.FixedWindow{Duration: 1, Unit: "second", Limit: 3}
+		if err := json.Unmarshal([]byte(data), &fw); err == nil {
+			t.Fatalf("%s: got nil error", data)
+		}
+		if fw.Duration != 1 || fw.Unit != "second" || fw.Limit != 3 {
+			t.Fatalf("%s: failed decode changed the limiter to duration %d, unit %q, limit %d", data, fw.Duration, fw.Unit, fw.Limit)
+		}
+	}
+}
+
+func TestJSONUnknownUnitError(t *testing.T) {
+	var fw ratelimit.FixedWindow
+	err := json.Unmarshal([]byte(`{"duration":1,"unit":"fortnight","limit":5}`), &fw)
+	if err == nil || !strings.Contains(err.Error(), "fortnight") {
+		t.Fatalf("got %v, want an error naming the unit", err)
+	}
+}
@@ -0,0 +1,274 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// KeyedLimiter keeps a separate FixedWindow for every key, e.g. one per
+// client IP or API key. Per-key limiters are created on first use from the
+// Duration, Unit, Limit and Window fields, which have the same meaning as on
+// FixedWindow.
+//
+// Each key costs a FixedWindow. Set IdleTTL to evict keys that have not been
+// seen for that long, and MaxKeys to evict the least recently used key once
+// there are that many; otherwise entries are kept until Stop and memory
+// grows with the number of distinct keys. An evicted key starts afresh with
+// a new limiter the next time it is seen.
+//
+// A KeyedLimiter must not be copied after first use.
+//
+// GlobalLimit, when set, additionally caps the requests of all keys combined
+// per window. A request is accepted only if it fits in both its key's window
+// and the global one.
+type KeyedLimiter struct {
+	Duration uint64
+	Unit     string
+	Limit    uint64
+	Window   time.Duration
+	IdleTTL  time.Duration
+	MaxKeys  int
+
+	GlobalLimit uint64
+
+	global   *FixedWindow
+	limiters map[string]*keyedEntry
+	lru      *list.List // of *keyedEntry, most recently used first
+	mu       sync.Mutex
+	done     chan struct{}
+	stop     bool
+}
+
+type keyedEntry struct {
+	key      string
+	fw       *FixedWindow
+	lastSeen time.Time
+	elem     *list.Element
+}
+
+// Validate checks the per-key configuration.
+func (kl *KeyedLimiter) Validate() error {
+	if kl.IdleTTL < 0 {
+		return errors.New("idle TTL must not be negative")
+	}
+	if kl.MaxKeys < 0 {
+		return errors.New("max keys must not be negative")
+	}
+	if kl.GlobalLimit > 0 {
+		global := kl.template()
+		global.Limit = kl.GlobalLimit
+		if err := global.Validate(); err != nil {
+			return err
+		}
+	}
+	return kl.template().Validate()
+}
+
+// Do validates the configuration and prepares the limiter for use. When
+// IdleTTL is set it also starts the goroutine that evicts idle keys.
+func (kl *KeyedLimiter) Do() error {
+	if err := kl.Validate(); err != nil {
+		return err
+	}
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	if kl.stop {
+		return ErrStopped
+	}
+	if kl.limiters != nil {
+		return errors.New("rate limiter already started")
+	}
+	if kl.GlobalLimit > 0 {
+		global := kl.template()
+		global.Limit = kl.GlobalLimit
+		if err := global.Do(); err != nil {
+			return err
+		}
+		kl.global = global
+	}
+	kl.limiters = make(map[string]*keyedEntry)
+	kl.lru = list.New()
+	if kl.IdleTTL > 0 {
+		kl.done = make(chan struct{})
+		go kl.sweep(kl.done)
+	}
+	return nil
+}
+
+// Accept reports whether a request for key fits in that key's window and,
+// if GlobalLimit is set, in the global one. A request rejected by the global
+// window does not use up any of the key's window.
+func (kl *KeyedLimiter) Accept(key string) bool {
+	return kl.AcceptN(key, 1)
+}
+
+// AcceptN is like Accept but for a request costing n units, which are
+// counted all at once or not at all, as with FixedWindow.AcceptN.
+func (kl *KeyedLimiter) AcceptN(key string, n uint64) bool {
+	ok, _ := kl.acceptN(key, n)
+	return ok
+}
+
+// AcceptCtx is like Accept but first checks ctx, returning its error
+// without using up a slot if ctx is already done. It returns ErrStopped if
+// the limiter has been stopped.
+func (kl *KeyedLimiter) AcceptCtx(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return kl.acceptN(key, 1)
+}
+
+func (kl *KeyedLimiter) acceptN(key string, n uint64) (bool, error) {
+	fw, err := kl.get(key)
+	if err != nil {
+		return false, err
+	}
+	ok, _ := kl.acceptWith(fw, n)
+	return ok, nil
+}
+
+// acceptWith counts n units against fw, the limiter of some key, and the
+// global window if there is one.
+func (kl *KeyedLimiter) acceptWith(fw *FixedWindow, n uint64) (bool, Reason) {
+	if kl.global == nil {
+		return fw.AcceptNWithReason(n)
+	}
+	if ok, reason := kl.global.AcceptNWithReason(n); !ok {
+		return false, reason
+	}
+	if ok, reason := fw.AcceptNWithReason(n); !ok {
+		kl.global.Refund(n)
+		return false, reason
+	}
+	return true, ReasonOK
+}
+
+// Refund gives back n units accepted for key in the current window, and in
+// the global window if GlobalLimit is set. Keys that have been evicted
+// since are ignored.
+func (kl *KeyedLimiter) Refund(key string, n uint64) {
+	fw, ok := kl.lookup(key)
+	if !ok {
+		return
+	}
+	fw.Refund(n)
+	if kl.global != nil {
+		kl.global.Refund(n)
+	}
+}
+
+// Stop stops every per-key limiter. Accept rejects every request afterwards.
+func (kl *KeyedLimiter) Stop() {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	if kl.stop {
+		return
+	}
+	kl.stop = true
+	if kl.done != nil {
+		close(kl.done)
+	}
+	if kl.global != nil {
+		kl.global.Stop()
+	}
+	for _, e := range kl.limiters {
+		e.fw.Stop()
+		kl.evict(e)
+	}
+}
+
+// get returns the limiter for key, creating and starting it if needed.
+func (kl *KeyedLimiter) get(key string) (*FixedWindow, error) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	if kl.stop {
+		return nil, ErrStopped
+	}
+	if kl.limiters == nil {
+		return nil, errNotStarted
+	}
+	now := time.Now()
+	if e, ok := kl.limiters[key]; ok {
+		e.lastSeen = now
+		kl.lru.MoveToFront(e.elem)
+		return e.fw, nil
+	}
+	fw := kl.template()
+	if err := fw.Do(); err != nil {
+		return nil, err
+	}
+	if kl.MaxKeys > 0 && len(kl.limiters) >= kl.MaxKeys {
+		kl.evict(kl.lru.Back().Value.(*keyedEntry))
+	}
+	e := &keyedEntry{key: key, fw: fw, lastSeen: now}
+	e.elem = kl.lru.PushFront(e)
+	kl.limiters[key] = e
+	return fw, nil
+}
+
+// lookup returns the limiter for key without creating one or counting as
+// a use of the key.
+func (kl *KeyedLimiter) lookup(key string) (*FixedWindow, bool) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	e, ok := kl.limiters[key]
+	if !ok {
+		return nil, false
+	}
+	return e.fw, true
+}
+
+// evict forgets the limiter of e. It is not stopped: a request that got it
+// from get just before may still be using it, and a FixedWindow holds no
+// goroutine that needs stopping. kl.mu must be held.
+func (kl *KeyedLimiter) evict(e *keyedEntry) {
+	kl.lru.Remove(e.elem)
+	delete(kl.limiters, e.key)
+}
+
+// sweep periodically evicts keys idle for longer than IdleTTL. A key is
+// touched under kl.mu before its limiter is used, so a key that is in use
+// is never considered idle.
+func (kl *KeyedLimiter) sweep(done <-chan struct{}) {
+	interval := kl.IdleTTL / 2
+	if interval <= 0 {
+		interval = kl.IdleTTL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			kl.evictIdle(now)
+		}
+	}
+}
+
+func (kl *KeyedLimiter) evictIdle(now time.Time) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	// The least recently used keys are at the back of the list.
+	for el := kl.lru.Back(); el != nil; {
+		e := el.Value.(*keyedEntry)
+		if now.Sub(e.lastSeen) < kl.IdleTTL {
+			break
+		}
+		el = el.Prev()
+		kl.evict(e)
+	}
+}
+
+func (kl *KeyedLimiter) template() *FixedWindow {
+	return &FixedWindow{
+		Duration: kl.Duration,
+		Unit:     kl.Unit,
+		Limit:    kl.Limit,
+		Window:   kl.Window,
+	}
+}
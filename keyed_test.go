@@ -0,0 +1,194 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// startKeyed starts kl and stops it when the test ends.
+func startKeyed(t *testing.T, kl *ratelimit.KeyedLimiter) *ratelimit.KeyedLimiter {
+	t.Helper()
+	if err := kl.Do(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(kl.Stop)
+	return kl
+}
+
+func TestKeyedIndependentKeys(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Duration: 1, Unit: "minute", Limit: 2})
+	if !kl.Accept("a") || !kl.Accept("a") {
+		t.Fatal("key a rejected within its limit")
+	}
+	if kl.Accept("a") {
+		t.Fatal("key a accepted over its limit")
+	}
+	if !kl.Accept("b") || !kl.Accept("b") {
+		t.Fatal("key b rejected because key a is full")
+	}
+	if kl.Accept("b") {
+		t.Fatal("key b accepted over its limit")
+	}
+}
+
+func TestKeyedIdleTTL(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Duration: 1, Unit: "minute", Limit: 1, IdleTTL: 20 * time.Millisecond})
+	kl.Accept("idle")
+	if !kl.Has("idle") {
+		t.Fatal("key not kept after Accept")
+	}
+	deadline := time.Now().Add(time.Second)
+	for kl.Has("idle") {
+		if time.Now().After(deadline) {
+			t.Fatal("idle key still present 1s after its 20ms TTL")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// An evicted key starts afresh.
+	if !kl.Accept("idle") {
+		t.Fatal("evicted key rejected")
+	}
+}
+
+func TestKeyedStopBeforeDo(t *testing.T) {
+	before := runtime.NumGoroutine()
+	kl := &ratelimit.KeyedLimiter{Duration: 1, Unit: "minute", Limit: 1, IdleTTL: time.Millisecond}
+	kl.Stop()
+	if err := kl.Do(); err != ratelimit.ErrStopped {
+		t.Fatalf("Do after Stop returned %v, want ErrStopped", err)
+	}
+	kl.Stop()
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("%d goroutines after Do on a stopped limiter, want %d", n, before)
+	}
+}
+
+func TestKeyedEvictionKeepsLimiterUsable(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Duration: 1, Unit: "minute", Limit: 1, MaxKeys: 1})
+	fwA, err := kl.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b evicts a while a request for a still holds its limiter.
+	if _, err := kl.Get("b"); err != nil {
+		t.Fatal(err)
+	}
+	if kl.Has("a") {
+		t.Fatal("key a not evicted")
+	}
+	if !kl.AcceptWith(fwA, 1) {
+		t.Fatal("request holding an evicted limiter rejected")
+	}
+}
+
+func TestKeyedMaxKeysEvictsLeastRecentlyUsed(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Duration: 1, Unit: "minute", Limit: 1, MaxKeys: 2})
+	kl.Accept("a")
+	kl.Accept("b")
+	// Using a again makes b the least recently used.
+	kl.Accept("a")
+	kl.Accept("c")
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if got := kl.Has(key); got != want {
+			t.Fatalf("key %s kept %v, want %v", key, got, want)
+		}
+	}
+	// An evicted key starts afresh.
+	if !kl.Accept("b") {
+		t.Fatal("evicted key b rejected")
+	}
+	if kl.Accept("b") {
+		t.Fatal("key b accepted over its limit")
+	}
+}
+
+func TestKeyedGlobalLimit(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Duration: 1, Unit: "minute", Limit: 5, GlobalLimit: 2})
+	if !kl.Accept("a") || !kl.Accept("b") {
+		t.Fatal("rejected within the global limit")
+	}
+	if kl.Accept("c") || kl.Accept("a") {
+		t.Fatal("accepted over the global limit")
+	}
+	// The requests rejected by the global window used none of their keys'.
+	for key, want := range map[string]uint64{"a": 4, "c": 5} {
+		fw, err := kl.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fw.Remaining(); got != want {
+			t.Fatalf("key %s remaining %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestKeyedAcceptN(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Duration: 1, Unit: "minute", Limit: 10})
+	if !kl.AcceptN("heavy", 10) {
+		t.Fatal("expensive request rejected by a fresh key")
+	}
+	if kl.Accept("heavy") {
+		t.Fatal("key accepted after an expensive request used its budget")
+	}
+	if kl.AcceptN("light", 11) {
+		t.Fatal("accepted a request costing more than the limit")
+	}
+	// The rejected request used none of light's budget.
+	for i := range 10 {
+		if !kl.Accept("light") {
+			t.Fatalf("request %d for another key rejected", i)
+		}
+	}
+}
+
+func TestKeyedAcceptCtx(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Window: time.Hour, Limit: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ok, err := kl.AcceptCtx(ctx, "a"); ok || !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled context: got %v, %v, want false, %v", ok, err, context.Canceled)
+	}
+	if kl.Has("a") {
+		t.Fatal("cancelled AcceptCtx created a limiter for its key")
+	}
+
+	// Each key has its own slot, untouched by the cancelled call.
+	for _, key := range []string{"a", "b"} {
+		if ok, err := kl.AcceptCtx(context.Background(), key); !ok || err != nil {
+			t.Fatalf("key %s: got %v, %v, want true, nil", key, ok, err)
+		}
+		if ok, err := kl.AcceptCtx(context.Background(), key); ok || err != nil {
+			t.Fatalf("key %s over its limit: got %v, %v, want false, nil", key, ok, err)
+		}
+	}
+
+	kl.Stop()
+	if _, err := kl.AcceptCtx(context.Background(), "a"); !errors.Is(err, ratelimit.ErrStopped) {
+		t.Fatalf("after Stop: got %v, want %v", err, ratelimit.ErrStopped)
+	}
+}
+
+func TestKeyedBeforeDo(t *testing.T) {
+	kl := &ratelimit.KeyedLimiter{Window: time.Minute, Limit: 1}
+	if kl.Accept("a") {
+		t.Fatal("accepted before Do")
+	}
+	kl.Refund("a", 1)
+
+	kl.Stop()
+	if kl.Accept("a") || kl.AcceptN("a", 1) {
+		t.Fatal("accepted after Stop before Do")
+	}
+	if _, err := kl.AcceptCtx(context.Background(), "a"); !errors.Is(err, ratelimit.ErrStopped) {
+		t.Fatalf("AcceptCtx after Stop before Do returned %v, want %v", err, ratelimit.ErrStopped)
+	}
+	if err := kl.Do(); !errors.Is(err, ratelimit.ErrStopped) {
+		t.Fatalf("Do after Stop returned %v, want %v", err, ratelimit.ErrStopped)
+	}
+}
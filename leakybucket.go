@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// LeakyBucket admits requests into a bucket of Capacity that drains at
+// LeakRate requests per Unit. Accept succeeds while the bucket has room.
+//
+// Because the bucket drains one request at a time at a steady pace, the
+// long-run acceptance rate is LeakRate per Unit with bursts bounded by
+// Capacity, which gives downstreams a smoother load than FixedWindow.
+//
+// When the bucket is full a new request is rejected, unless DropOldest is
+// set: then the oldest request in the bucket is dropped to make room, so the
+// newest requests are the ones let through, and OnDrop is called.
+type LeakyBucket struct {
+	Capacity uint64
+	LeakRate uint64
+	Unit     string
+
+	DropOldest bool
+	// OnDrop, if set, is called for every request dropped by DropOldest. It
+	// runs outside the bucket's lock on the goroutine that called Accept.
+	OnDrop func()
+
+	queued uint64
+	mu     *sync.Mutex
+	ticker *time.Ticker
+	done   chan struct{}
+	stop   bool
+}
+
+// Validate checks that the capacity, leak rate and unit are usable.
+func (lb *LeakyBucket) Validate() error {
+	if lb.Capacity == 0 {
+		return errors.New("capacity must be greater than zero")
+	}
+	if lb.LeakRate == 0 {
+		return errors.New("leak rate must be greater than zero")
+	}
+	if err := validateUnit(lb.Unit); err != nil {
+		return err
+	}
+	if lb.interval() <= 0 {
+		return errors.New("leak rate is too high for the unit")
+	}
+	return nil
+}
+
+// Do validates the configuration and starts draining the bucket.
+func (lb *LeakyBucket) Do() error {
+	if err := lb.Validate(); err != nil {
+		return err
+	}
+	if lb.mu == nil {
+		lb.mu = &sync.Mutex{}
+	}
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.stop {
+		return ErrStopped
+	}
+	if lb.ticker != nil {
+		return errors.New("rate limiter already started")
+	}
+	lb.ticker = time.NewTicker(lb.interval())
+	lb.done = make(chan struct{})
+	go lb.drain(lb.ticker, lb.done)
+	return nil
+}
+
+// Accept adds the request to the bucket if there is room. With DropOldest
+// it accepts every request until Stop, dropping the oldest one when the
+// bucket is full.
+func (lb *LeakyBucket) Accept() bool {
+	lb.mu.Lock()
+	if lb.stop {
+		lb.mu.Unlock()
+		return false
+	}
+	if lb.queued < lb.Capacity {
+		lb.queued++
+		lb.mu.Unlock()
+		return true
+	}
+	lb.mu.Unlock()
+	if !lb.DropOldest {
+		return false
+	}
+	// The new request takes the dropped one's place, so the level of the
+	// bucket stays the same.
+	if lb.OnDrop != nil {
+		lb.OnDrop()
+	}
+	return true
+}
+
+// Refund takes n requests back out of the bucket.
+func (lb *LeakyBucket) Refund(n uint64) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.queued -= min(n, lb.queued)
+}
+
+// Stop stops draining the bucket. Accept rejects every request afterwards.
+// It may be called before Do.
+func (lb *LeakyBucket) Stop() {
+	if lb.mu == nil {
+		lb.stop = true
+		return
+	}
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.stop {
+		return
+	}
+	lb.stop = true
+	if lb.ticker != nil {
+		lb.ticker.Stop()
+		close(lb.done)
+	}
+}
+
+// drain lets one request out of the bucket per tick.
+func (lb *LeakyBucket) drain(ticker *time.Ticker, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			lb.mu.Lock()
+			if lb.queued > 0 {
+				lb.queued--
+			}
+			lb.mu.Unlock()
+		}
+	}
+}
+
+// interval returns the time it takes to drain one request.
+func (lb *LeakyBucket) interval() time.Duration {
+	unit, _ := unitDuration(lb.Unit)
+	return unit / time.Duration(lb.LeakRate)
+}
@@ -0,0 +1,95 @@
+package ratelimit_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// TestLeakyBucketLeakRate offers far more requests than the bucket drains
+// and checks that acceptances track the leak rate once it is full.
+func TestLeakyBucketLeakRate(t *testing.T) {
+	lb := &ratelimit.LeakyBucket{Capacity: 2, LeakRate: 50, Unit: "second"}
+	if err := lb.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer lb.Stop()
+	var _ ratelimit.RateLimiter = lb
+
+	start := time.Now()
+	accepted := 0
+	for time.Since(start) < 400*time.Millisecond {
+		if lb.Accept() {
+			accepted++
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// The initial 2, plus one every 20ms. A slow machine can only drain
+	// fewer, so allow more room below than above.
+	want := 2 + int(time.Since(start)/(20*time.Millisecond))
+	if accepted > want+2 || accepted < want/2 {
+		t.Fatalf("accepted %d, want about %d", accepted, want)
+	}
+}
+
+func TestLeakyBucketStopBeforeDo(t *testing.T) {
+	before := runtime.NumGoroutine()
+	lb := &ratelimit.LeakyBucket{Capacity: 1, LeakRate: 1, Unit: "second"}
+	lb.Stop()
+	if err := lb.Do(); err != ratelimit.ErrStopped {
+		t.Fatalf("Do after Stop returned %v, want ErrStopped", err)
+	}
+	lb.Stop()
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("%d goroutines after Do on a stopped bucket, want %d", n, before)
+	}
+}
+
+func TestLeakyBucketStopEndsDrain(t *testing.T) {
+	before := runtime.NumGoroutine()
+	lb := &ratelimit.LeakyBucket{Capacity: 1, LeakRate: 1, Unit: "second"}
+	if err := lb.Do(); err != nil {
+		t.Fatal(err)
+	}
+	lb.Stop()
+	waitGoroutines(t, before)
+}
+
+func TestLeakyBucketOverflow(t *testing.T) {
+	for _, dropOldest := range []bool{false, true} {
+		var dropped int
+		lb := &ratelimit.LeakyBucket{Capacity: 3, LeakRate: 1, Unit: "hour", DropOldest: dropOldest, OnDrop: func() { dropped++ }}
+		if err := lb.Do(); err != nil {
+			t.Fatal(err)
+		}
+		for i := range 3 {
+			if !lb.Accept() {
+				t.Fatalf("DropOldest %v: request %d within capacity rejected", dropOldest, i)
+			}
+		}
+		// At capacity, reject-new turns the request away while drop-oldest
+		// lets it in in place of the oldest.
+		for i := range 2 {
+			if got := lb.Accept(); got != dropOldest {
+				t.Fatalf("DropOldest %v: request %d at capacity accepted %v", dropOldest, i, got)
+			}
+		}
+		if want := map[bool]int{false: 0, true: 2}[dropOldest]; dropped != want {
+			t.Fatalf("DropOldest %v: OnDrop ran %d times, want %d", dropOldest, dropped, want)
+		}
+		// Either way the bucket holds exactly its capacity.
+		lb.Refund(1)
+		if !lb.Accept() {
+			t.Fatalf("DropOldest %v: request after a refund rejected", dropOldest)
+		}
+		if want := map[bool]int{false: 0, true: 2}[dropOldest]; dropped != want {
+			t.Fatalf("DropOldest %v: OnDrop ran for a request that fitted", dropOldest)
+		}
+		lb.Stop()
+		if lb.Accept() {
+			t.Fatalf("DropOldest %v: accepted after Stop", dropOldest)
+		}
+	}
+}
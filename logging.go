@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// LoggingLimiter wraps rl so that every rejected Accept is logged to logger
+// as a warning, with the reason. If rl implements WindowReporter the record
+// also carries the limit and the requests remaining; accepted requests are
+// not logged.
+//
+// The returned limiter is a Wrapper, so HTTPMiddleware still finds the
+// WindowReporter and RetryReporter of rl, and a ReasonAccepter, so a
+// stopped rl is still answered with 503.
+func LoggingLimiter(rl RateLimiter, logger *slog.Logger) RateLimiter {
+	return &loggingLimiter{RateLimiter: rl, logger: logger}
+}
+
+type loggingLimiter struct {
+	RateLimiter
+	logger *slog.Logger
+}
+
+func (l *loggingLimiter) Accept() bool {
+	ok, _ := l.AcceptWithReason()
+	return ok
+}
+
+func (l *loggingLimiter) AcceptWithReason() (bool, Reason) {
+	ok, reason := AcceptWithReason(l.RateLimiter)
+	if ok {
+		return true, reason
+	}
+	attrs := []slog.Attr{slog.Time("at", time.Now()), slog.String("reason", reason.String())}
+	if wr, isReporter := As[WindowReporter](l.RateLimiter); isReporter {
+		attrs = append(attrs,
+			slog.Uint64("limit", wr.WindowLimit()),
+			slog.Uint64("remaining", wr.Remaining()),
+		)
+	}
+	l.logger.LogAttrs(context.Background(), slog.LevelWarn, "rate limit exceeded", attrs...)
+	return false, reason
+}
+
+func (l *loggingLimiter) Unwrap() Limiter { return l.RateLimiter }
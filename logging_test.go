@@ -0,0 +1,105 @@
+package ratelimit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestLoggingLimiter(t *testing.T) {
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 2})
+	var buf bytes.Buffer
+	rl := ratelimit.LoggingLimiter(fw, slog.New(slog.NewJSONHandler(&buf, nil)))
+	for range 2 {
+		if !rl.Accept() {
+			t.Fatal("request within the limit rejected")
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("accepted requests logged %q", buf.String())
+	}
+	if rl.Accept() {
+		t.Fatal("request over the limit accepted")
+	}
+	var record struct {
+		Level     string `json:"level"`
+		Msg       string `json:"msg"`
+		Reason    string `json:"reason"`
+		Limit     uint64 `json:"limit"`
+		Remaining uint64 `json:"remaining"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decoding %q: %v", buf.String(), err)
+	}
+	if record.Level != "WARN" || record.Msg != "rate limit exceeded" {
+		t.Fatalf("got level %q, message %q, want WARN, rate limit exceeded", record.Level, record.Msg)
+	}
+	if record.Reason != "limit reached" || record.Limit != 2 || record.Remaining != 0 {
+		t.Fatalf("got reason %q, limit %d, remaining %d, want limit reached, 2, 0", record.Reason, record.Limit, record.Remaining)
+	}
+}
+
+func TestHTTPMiddlewareThroughWrappers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tc := range []struct {
+		name string
+		wrap func(ratelimit.RateLimiter) ratelimit.RateLimiter
+	}{
+		{"logging", func(rl ratelimit.RateLimiter) ratelimit.RateLimiter {
+			return ratelimit.LoggingLimiter(rl, logger)
+		}},
+		{"tiered", func(rl ratelimit.RateLimiter) ratelimit.RateLimiter {
+			return ratelimit.TieredLimiter(ratelimit.NewFixedWindow(time.Minute, 100), rl)
+		}},
+		{"swappable", func(rl ratelimit.RateLimiter) ratelimit.RateLimiter {
+			return ratelimit.NewSwappableLimiter(rl)
+		}},
+		{"nested", func(rl ratelimit.RateLimiter) ratelimit.RateLimiter {
+			return ratelimit.LoggingLimiter(ratelimit.NewSwappableLimiter(rl), logger)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			fw := &ratelimit.FixedWindow{Window: time.Minute, Limit: 2, MinInterval: 30 * time.Second, Clock: clock}
+			rl := tc.wrap(fw)
+			if err := rl.Do(); err != nil {
+				t.Fatal(err)
+			}
+			defer rl.Stop()
+			h := ratelimit.HTTPMiddleware(rl)(okHandler)
+
+			rec := serve(h, "/")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("first request: status %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("X-RateLimit-Limit"); got != "2" {
+				t.Fatalf("X-RateLimit-Limit %q, want 2", got)
+			}
+			if got := rec.Header().Get("X-RateLimit-Remaining"); got != "1" {
+				t.Fatalf("X-RateLimit-Remaining %q, want 1", got)
+			}
+
+			// The window has room but MinInterval has twenty seconds to go,
+			// which only RetryAfter knows; the window resets in fifty.
+			clock.Add(10 * time.Second)
+			rec = serve(h, "/")
+			if rec.Code != http.StatusTooManyRequests {
+				t.Fatalf("second request: status %d, want 429", rec.Code)
+			}
+			if got := rec.Header().Get("Retry-After"); got != "20" {
+				t.Fatalf("Retry-After %q, want 20", got)
+			}
+
+			fw.Stop()
+			if rec := serve(h, "/"); rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("after Stop: status %d, want 503", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package ratelimit
+
+// MetricsCollector receives a limiter's decisions, so that they can be
+// exported to any metrics system. The promlimit package provides one for
+// Prometheus. Methods are called on the goroutine that asked the limiter and
+// must be safe for concurrent use.
+type MetricsCollector interface {
+	// IncAccepted counts an accepted request.
+	IncAccepted()
+	// IncRejected counts a rejected request.
+	IncRejected()
+	// ObserveUtilization records the used fraction of the current window,
+	// from 0 to 1, after a decision.
+	ObserveUtilization(float64)
+}
+
+// WithMetrics reports the limiter's decisions to mc.
+func WithMetrics(mc MetricsCollector) Option {
+	return func(fw *FixedWindow) { fw.Metrics = mc }
+}
+
+// record reports a decision to fw.Metrics. fw.mu must not be held.
+func (fw *FixedWindow) record(accepted bool) {
+	if accepted {
+		fw.Metrics.IncAccepted()
+	} else {
+		fw.Metrics.IncRejected()
+	}
+	fw.Metrics.ObserveUtilization(fw.Utilization())
+}
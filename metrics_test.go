@@ -0,0 +1,58 @@
+package ratelimit_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// fakeMetrics counts the calls made to it.
+type fakeMetrics struct {
+	mu                 sync.Mutex
+	accepted, rejected int
+	utilization        []float64
+}
+
+func (m *fakeMetrics) IncAccepted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accepted++
+}
+
+func (m *fakeMetrics) IncRejected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejected++
+}
+
+func (m *fakeMetrics) ObserveUtilization(u float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.utilization = append(m.utilization, u)
+}
+
+func TestMetrics(t *testing.T) {
+	m := &fakeMetrics{}
+	fw, err := ratelimit.New(ratelimit.WithWindow(time.Hour), ratelimit.WithLimit(4), ratelimit.WithMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+	for range 6 {
+		fw.Accept()
+	}
+	if m.accepted != 4 || m.rejected != 2 {
+		t.Fatalf("got %d accepted, %d rejected, want 4 and 2", m.accepted, m.rejected)
+	}
+	want := []float64{0.25, 0.5, 0.75, 1, 1, 1}
+	if len(m.utilization) != len(want) {
+		t.Fatalf("utilization %v, want %v", m.utilization, want)
+	}
+	for i := range want {
+		if m.utilization[i] != want[i] {
+			t.Fatalf("utilization %v, want %v", m.utilization, want)
+		}
+	}
+}
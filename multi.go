@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Refunder is implemented by limiters that can give back units they have
+// just accepted, for example when a later check in a MultiLimiter fails.
+//
+// Refund is meant to undo an accept straight away. If the limiter's window
+// has moved on in between, the units are returned to the new window.
+type Refunder interface {
+	Refund(n uint64)
+}
+
+// MultiLimiter enforces several limits at once, such as 10 per second and
+// 1000 per hour. Accept succeeds only if every limiter accepts; if any of
+// them rejects, the ones that had already accepted are refunded, so a
+// rejected request uses up none of the limits.
+//
+// Every limiter except the last must implement Refunder and be able to undo
+// an accept completely, so a FixedWindow backed by a Store or with a
+// MinInterval must be last.
+type MultiLimiter struct {
+	Limiters []RateLimiter
+}
+
+// Validate checks every limiter and that all but the last can be refunded.
+func (m *MultiLimiter) Validate() error {
+	if len(m.Limiters) == 0 {
+		return errors.New("at least one limiter is required")
+	}
+	for i, rl := range m.Limiters {
+		if err := rl.Validate(); err != nil {
+			return fmt.Errorf("limiter %d: %w", i, err)
+		}
+		if i == len(m.Limiters)-1 {
+			break
+		}
+		if _, ok := rl.(Refunder); !ok {
+			return fmt.Errorf("limiter %d: %T cannot refund, so it must be last", i, rl)
+		}
+		if fw, ok := rl.(*FixedWindow); ok && fw.Store != nil {
+			return fmt.Errorf("limiter %d: a FixedWindow backed by a Store cannot refund, so it must be last", i)
+		}
+		if fw, ok := rl.(*FixedWindow); ok && fw.MinInterval > 0 {
+			return fmt.Errorf("limiter %d: a refund does not undo MinInterval, so it must be last", i)
+		}
+	}
+	return nil
+}
+
+// Do validates the configuration and starts every limiter. If one fails to
+// start, the ones already started are stopped again.
+func (m *MultiLimiter) Do() error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	for i, rl := range m.Limiters {
+		if err := rl.Do(); err != nil {
+			for _, started := range m.Limiters[:i] {
+				started.Stop()
+			}
+			return fmt.Errorf("limiter %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Accept reports whether every limiter accepts the request.
+//
+// The limiters are not locked together, so a concurrent request may briefly
+// see units that are about to be refunded and be rejected too. Units are
+// never overcommitted.
+func (m *MultiLimiter) Accept() bool {
+	for i, rl := range m.Limiters {
+		if !rl.Accept() {
+			refund(m.Limiters[:i], 1)
+			return false
+		}
+	}
+	return true
+}
+
+// Stop stops every limiter.
+func (m *MultiLimiter) Stop() {
+	for _, rl := range m.Limiters {
+		rl.Stop()
+	}
+}
+
+// refund gives n units back to every limiter that supports it.
+func refund(limiters []RateLimiter, n uint64) {
+	for _, rl := range limiters {
+		if r, ok := rl.(Refunder); ok {
+			r.Refund(n)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package ratelimit_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestMultiLimiterRejectsWithoutCharging(t *testing.T) {
+	perSecond := ratelimit.NewFixedWindow(time.Second, 10)
+	perHour := ratelimit.NewFixedWindow(time.Hour, 2)
+	m := &ratelimit.MultiLimiter{Limiters: []ratelimit.RateLimiter{perSecond, perHour}}
+	if err := m.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	for i, want := range []bool{true, true, false, false} {
+		if got := m.Accept(); got != want {
+			t.Fatalf("request %d: got %v, want %v", i, got, want)
+		}
+	}
+	// The per-second limit allowed the rejected requests but was refunded.
+	if got := perSecond.Remaining(); got != 8 {
+		t.Fatalf("per-second remaining %d, want 8", got)
+	}
+	if got := perHour.Remaining(); got != 0 {
+		t.Fatalf("per-hour remaining %d, want 0", got)
+	}
+}
+
+func TestMultiLimiterValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		first ratelimit.RateLimiter
+		want  string
+	}{
+		{"store", &ratelimit.FixedWindow{Window: time.Second, Limit: 1, Store: &ratelimit.MemoryStore{}}, "Store"},
+		{"min interval", &ratelimit.FixedWindow{Window: time.Second, Limit: 1, MinInterval: time.Millisecond}, "MinInterval"},
+		{"no refund", ratelimit.NoOp{}, "cannot refund"},
+	} {
+		m := &ratelimit.MultiLimiter{Limiters: []ratelimit.RateLimiter{tc.first, ratelimit.NewFixedWindow(time.Hour, 1)}}
+		if err := m.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: got %v, want an error mentioning %q", tc.name, err, tc.want)
+		}
+		// The same limiter is fine last, where it is never refunded.
+		m.Limiters[0], m.Limiters[1] = m.Limiters[1], m.Limiters[0]
+		if err := m.Validate(); err != nil {
+			t.Fatalf("%s last: %v", tc.name, err)
+		}
+	}
+}
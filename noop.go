@@ -0,0 +1,33 @@
+package ratelimit
+
+// NoOp is a RateLimiter that accepts every request. Use it where rate
+// limiting is turned off by configuration, so callers need no special case.
+type NoOp struct{}
+
+// Validate always succeeds.
+func (NoOp) Validate() error { return nil }
+
+// Do does nothing.
+func (NoOp) Do() error { return nil }
+
+// Accept always returns true.
+func (NoOp) Accept() bool { return true }
+
+// Stop does nothing.
+func (NoOp) Stop() {}
+
+// AlwaysDeny is a RateLimiter that rejects every request, for example to
+// shed all load while a service is in maintenance.
+type AlwaysDeny struct{}
+
+// Validate always succeeds.
+func (AlwaysDeny) Validate() error { return nil }
+
+// Do does nothing.
+func (AlwaysDeny) Do() error { return nil }
+
+// Accept always returns false.
+func (AlwaysDeny) Accept() bool { return false }
+
+// Stop does nothing.
+func (AlwaysDeny) Stop() {}
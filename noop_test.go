@@ -0,0 +1,44 @@
+package ratelimit_test
+
+import (
+	"testing"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestNoOp(t *testing.T) {
+	var rl ratelimit.RateLimiter = ratelimit.NoOp{}
+	if err := rl.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.Do(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 1000 {
+		if !rl.Accept() {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	rl.Stop()
+	rl.Stop()
+}
+
+func TestAlwaysDeny(t *testing.T) {
+	var rl ratelimit.RateLimiter = ratelimit.AlwaysDeny{}
+	if err := rl.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.Do(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 1000 {
+		if rl.Accept() {
+			t.Fatalf("request %d accepted", i)
+		}
+	}
+	rl.Stop()
+	rl.Stop()
+	if err := rl.Do(); err != nil {
+		t.Fatalf("Do after Stop: %v", err)
+	}
+}
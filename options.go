@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+)
+
+// Option configures a FixedWindow built by New.
+type Option func(*FixedWindow)
+
+// WithWindow sets the window length directly. It cannot be combined with
+// WithDuration or WithUnit.
+func WithWindow(d time.Duration) Option {
+	return func(fw *FixedWindow) { fw.Window = d }
+}
+
+// WithDuration sets the window length in units of WithUnit.
+func WithDuration(n uint64) Option {
+	return func(fw *FixedWindow) { fw.Duration = n }
+}
+
+// WithUnit sets the unit of WithDuration. If WithDuration is not given the
+// window is one unit long.
+func WithUnit(unit string) Option {
+	return func(fw *FixedWindow) { fw.Unit = unit }
+}
+
+// WithLimit sets the number of requests allowed per window.
+func WithLimit(limit uint64) Option {
+	return func(fw *FixedWindow) { fw.Limit = limit }
+}
+
+// WithMinInterval sets the least time allowed between accepted requests.
+func WithMinInterval(d time.Duration) Option {
+	return func(fw *FixedWindow) { fw.MinInterval = d }
+}
+
+// WithBurst allows up to n requests per window beyond the limit.
+func WithBurst(n uint64) Option {
+	return func(fw *FixedWindow) { fw.Burst = n }
+}
+
+// WithInitialCount starts the first window with n requests already counted.
+func WithInitialCount(n uint64) Option {
+	return func(fw *FixedWindow) { fw.InitialCount = n }
+}
+
+// WithJitter offsets the window boundaries by a random amount up to d.
+func WithJitter(d time.Duration) Option {
+	return func(fw *FixedWindow) { fw.Jitter = d }
+}
+
+// WithResetOnFirstRequest starts each window with its first request rather
+// than at fixed intervals.
+func WithResetOnFirstRequest() Option {
+	return func(fw *FixedWindow) { fw.ResetOnFirstRequest = true }
+}
+
+// New returns a started FixedWindow configured by opts, so there is no
+// separate Do step to forget.
+func New(opts ...Option) (*FixedWindow, error) {
+	fw := &FixedWindow{}
+	for _, opt := range opts {
+		opt(fw)
+	}
+	if fw.Window != 0 && (fw.Duration != 0 || fw.Unit != "") {
+		return nil, errors.New("window cannot be combined with duration or unit")
+	}
+	if fw.Unit != "" && fw.Duration == 0 {
+		fw.Duration = 1
+	}
+	if err := fw.Do(); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
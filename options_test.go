@@ -0,0 +1,81 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   []ratelimit.Option
+		window time.Duration
+	}{
+		{"window", []ratelimit.Option{ratelimit.WithWindow(time.Second), ratelimit.WithLimit(2)}, time.Second},
+		{"unit defaults to one", []ratelimit.Option{ratelimit.WithUnit("minute"), ratelimit.WithLimit(2)}, time.Minute},
+		{"duration and unit", []ratelimit.Option{ratelimit.WithDuration(3), ratelimit.WithUnit("second"), ratelimit.WithLimit(2)}, 3 * time.Second},
+		{"order does not matter", []ratelimit.Option{ratelimit.WithLimit(2), ratelimit.WithUnit("second"), ratelimit.WithDuration(3)}, 3 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fw, err := ratelimit.New(append(tc.opts, ratelimit.WithClock(newFakeClock()))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fw.Stop()
+			if !fw.IsRunning() {
+				t.Fatal("New returned a limiter that is not started")
+			}
+			if got := fw.ResetIn(); got != tc.window {
+				t.Fatalf("window of %v, want %v", got, tc.window)
+			}
+			if got := fw.Remaining(); got != 2 {
+				t.Fatalf("remaining %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []ratelimit.Option
+	}{
+		{"window and unit", []ratelimit.Option{ratelimit.WithWindow(time.Second), ratelimit.WithUnit("second"), ratelimit.WithLimit(1)}},
+		{"window and duration", []ratelimit.Option{ratelimit.WithWindow(time.Second), ratelimit.WithDuration(1), ratelimit.WithLimit(1)}},
+		{"no limit", []ratelimit.Option{ratelimit.WithWindow(time.Second)}},
+		{"no window", []ratelimit.Option{ratelimit.WithLimit(1)}},
+		{"duration without unit", []ratelimit.Option{ratelimit.WithDuration(1), ratelimit.WithLimit(1)}},
+		{"bad unit", []ratelimit.Option{ratelimit.WithUnit("week"), ratelimit.WithLimit(1)}},
+		{"negative window", []ratelimit.Option{ratelimit.WithWindow(-time.Second), ratelimit.WithLimit(1)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if fw, err := ratelimit.New(tc.opts...); err == nil {
+				fw.Stop()
+				t.Fatal("New succeeded")
+			}
+		})
+	}
+}
+
+func TestWithInitialCount(t *testing.T) {
+	clock := newFakeClock()
+	fw, err := ratelimit.New(ratelimit.WithWindow(time.Minute), ratelimit.WithLimit(5), ratelimit.WithInitialCount(3), ratelimit.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+	if got := fw.Remaining(); got != 2 {
+		t.Fatalf("remaining %d, want 2", got)
+	}
+	// Only the first window starts counted.
+	clock.Add(time.Minute)
+	if got := fw.Remaining(); got != 5 {
+		t.Fatalf("remaining %d in the next window, want 5", got)
+	}
+
+	if _, err := ratelimit.New(ratelimit.WithWindow(time.Minute), ratelimit.WithLimit(5), ratelimit.WithInitialCount(6)); err == nil {
+		t.Fatal("accepted an initial count above the limit")
+	}
+}
@@ -0,0 +1,76 @@
+// Package otellimit records ratelimit decisions on OpenTelemetry trace
+// spans. The OpenTelemetry dependency stays in this package's own module.
+package otellimit
+
+import (
+	"context"
+	"math"
+
+	"github.com/govi230/ratelimit"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/govi230/ratelimit/otellimit"
+
+// Limiter is a ratelimit.RateLimiter that records every decision of the
+// limiter it wraps as attributes of a trace span. It is a
+// ratelimit.Wrapper, so ratelimit.HTTPMiddleware still sends the wrapped
+// limiter's rate limit headers.
+type Limiter struct {
+	ratelimit.RateLimiter
+
+	tracer trace.Tracer
+}
+
+// TracedLimiter wraps rl, taking its tracer from the global tracer provider.
+func TracedLimiter(rl ratelimit.RateLimiter) *Limiter {
+	return &Limiter{RateLimiter: rl, tracer: otel.Tracer(tracerName)}
+}
+
+// Accept is AcceptContext with a background context, so the decision is
+// always recorded on a new root "ratelimit.Accept" span, detached from the
+// trace of the request it was made for. ratelimit.HTTPMiddleware calls
+// AcceptContextWithReason with the request's context instead, so the
+// decision is recorded on the request's span, e.g. the one started by
+// otelhttp. The gin, echo and gRPC middleware only call Accept.
+func (l *Limiter) Accept() bool {
+	return l.AcceptContext(context.Background())
+}
+
+// AcceptContext asks the wrapped limiter and sets ratelimit.accepted, and
+// ratelimit.remaining if the limiter implements ratelimit.WindowReporter,
+// on the span in ctx. If ctx carries no recording span, a new
+// "ratelimit.Accept" span is started and ended for the decision.
+func (l *Limiter) AcceptContext(ctx context.Context) bool {
+	ok, _ := l.AcceptContextWithReason(ctx)
+	return ok
+}
+
+// AcceptWithReason is like Accept but also returns why the wrapped limiter
+// decided as it did, if it can say.
+func (l *Limiter) AcceptWithReason() (bool, ratelimit.Reason) {
+	return l.AcceptContextWithReason(context.Background())
+}
+
+// Unwrap returns the wrapped limiter.
+func (l *Limiter) Unwrap() ratelimit.Limiter { return l.RateLimiter }
+
+// AcceptContextWithReason is like AcceptContext but also returns why the
+// wrapped limiter decided as it did, if it can say. It implements
+// ratelimit.ContextAccepter.
+func (l *Limiter) AcceptContextWithReason(ctx context.Context) (bool, ratelimit.Reason) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		_, span = l.tracer.Start(ctx, "ratelimit.Accept")
+		defer span.End()
+	}
+	ok, reason := ratelimit.AcceptWithReason(l.RateLimiter)
+	attrs := []attribute.KeyValue{attribute.Bool("ratelimit.accepted", ok)}
+	if wr, isReporter := ratelimit.As[ratelimit.WindowReporter](l.RateLimiter); isReporter {
+		attrs = append(attrs, attribute.Int64("ratelimit.remaining", int64(min(wr.Remaining(), math.MaxInt64))))
+	}
+	span.SetAttributes(attrs...)
+	return ok, reason
+}
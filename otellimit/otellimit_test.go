@@ -0,0 +1,120 @@
+package otellimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+	"github.com/govi230/ratelimit/otellimit"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record installs a tracer provider that keeps every ended span.
+func record(t *testing.T) (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(old) })
+	return tp, sr
+}
+
+func startLimiter(t *testing.T, limit uint64) *otellimit.Limiter {
+	t.Helper()
+	fw := ratelimit.NewFixedWindow(time.Hour, limit)
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fw.Stop)
+	return otellimit.TracedLimiter(fw)
+}
+
+func attrs(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestAcceptContextAnnotatesSpan(t *testing.T) {
+	tp, sr := record(t)
+	l := startLimiter(t, 1)
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	l.AcceptContext(ctx)
+	l.AcceptContext(ctx)
+	span.End()
+
+	ended := sr.Ended()
+	if len(ended) != 1 || ended[0].Name() != "request" {
+		t.Fatalf("got %d spans, want only the request span", len(ended))
+	}
+	got := attrs(ended[0])
+	// The second decision overwrites the first.
+	if v := got["ratelimit.accepted"]; v.Type() != attribute.BOOL || v.AsBool() {
+		t.Fatalf("ratelimit.accepted %v, want false", v.Emit())
+	}
+	if v := got["ratelimit.remaining"]; v.Type() != attribute.INT64 || v.AsInt64() != 0 {
+		t.Fatalf("ratelimit.remaining %v, want 0", v.Emit())
+	}
+}
+
+func TestAcceptStartsSpan(t *testing.T) {
+	_, sr := record(t)
+	l := startLimiter(t, 2)
+	l.Accept()
+
+	ended := sr.Ended()
+	if len(ended) != 1 || ended[0].Name() != "ratelimit.Accept" {
+		t.Fatalf("got %d spans, want one ratelimit.Accept span", len(ended))
+	}
+	if ended[0].Parent().IsValid() {
+		t.Fatal("ratelimit.Accept span has a parent")
+	}
+	got := attrs(ended[0])
+	if v := got["ratelimit.accepted"]; !v.AsBool() {
+		t.Fatalf("ratelimit.accepted %v, want true", v.Emit())
+	}
+	if v := got["ratelimit.remaining"]; v.AsInt64() != 1 {
+		t.Fatalf("ratelimit.remaining %v, want 1", v.Emit())
+	}
+}
+
+func TestHTTPMiddlewareAnnotatesRequestSpan(t *testing.T) {
+	tp, sr := record(t)
+	l := startLimiter(t, 1)
+	h := ratelimit.HTTPMiddleware(l)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+		span.End()
+		if rec.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i, rec.Code, want)
+		}
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "1" {
+			t.Fatalf("request %d: X-RateLimit-Limit %q, want 1", i, got)
+		}
+	}
+
+	ended := sr.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("got %d spans, want the two request spans", len(ended))
+	}
+	for i, s := range ended {
+		if s.Name() != "request" {
+			t.Fatalf("span %d is %q, want request", i, s.Name())
+		}
+		if got, want := attrs(s)["ratelimit.accepted"].AsBool(), i == 0; got != want {
+			t.Fatalf("request %d: ratelimit.accepted %v, want %v", i, got, want)
+		}
+	}
+}
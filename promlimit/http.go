@@ -0,0 +1,58 @@
+package promlimit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/govi230/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type acceptedKey struct{}
+
+// HTTPMiddleware is like ratelimit.HTTPMiddleware but also counts the
+// requests it accepts and rejects in <name>_http_accepted_total and
+// <name>_http_rejected_total, registered with reg and labelled with the
+// route returned by routeLabel.
+//
+// A nil routeLabel uses r.Pattern, the pattern of the http.ServeMux route
+// that matched. r.Pattern is only set for middleware inside the mux, i.e.
+// wrapping each handler given to ServeMux.Handle; wrapping the mux as a
+// whole leaves it empty. routeLabel should return a bounded set of values,
+// such as patterns rather than raw paths, to keep the number of series low.
+func HTTPMiddleware(rl ratelimit.Limiter, reg prometheus.Registerer, name string, routeLabel func(*http.Request) string, opts ...ratelimit.MiddlewareOption) (func(http.Handler) http.Handler, error) {
+	if routeLabel == nil {
+		routeLabel = func(r *http.Request) string { return r.Pattern }
+	}
+	accepted := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: name + "_http_accepted_total",
+		Help: "Number of HTTP requests accepted by the rate limiter.",
+	}, []string{"route"})
+	rejected := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: name + "_http_rejected_total",
+		Help: "Number of HTTP requests rejected by the rate limiter.",
+	}, []string{"route"})
+	for _, c := range []prometheus.Collector{accepted, rejected} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	limit := ratelimit.HTTPMiddleware(rl, opts...)
+	return func(next http.Handler) http.Handler {
+		// The limiting middleware only calls next for accepted requests.
+		h := limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*r.Context().Value(acceptedKey{}).(*bool) = true
+			next.ServeHTTP(w, r)
+		}))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var ok bool
+			route := routeLabel(r)
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), acceptedKey{}, &ok)))
+			if ok {
+				accepted.WithLabelValues(route).Inc()
+			} else {
+				rejected.WithLabelValues(route).Inc()
+			}
+		})
+	}, nil
+}
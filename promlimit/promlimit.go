@@ -0,0 +1,102 @@
+// Package promlimit exports ratelimit decisions as Prometheus metrics.
+// Its module is separate from ratelimit's, so only programs that import it
+// depend on the Prometheus client.
+package promlimit
+
+import (
+	"github.com/govi230/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a ratelimit.MetricsCollector that exports decisions as
+// Prometheus metrics. Set it as FixedWindow.Metrics to instrument a limiter
+// without wrapping it.
+type Collector struct {
+	accepted    prometheus.Counter
+	rejected    prometheus.Counter
+	utilization prometheus.Gauge
+}
+
+var _ ratelimit.MetricsCollector = (*Collector)(nil)
+
+// NewCollector registers three metrics with reg: <name>_accepted_total,
+// <name>_rejected_total and <name>_utilization, the last being the used
+// fraction of the current window.
+func NewCollector(reg prometheus.Registerer, name string) (*Collector, error) {
+	c := &Collector{
+		accepted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: name + "_accepted_total",
+			Help: "Number of requests accepted by the rate limiter.",
+		}),
+		rejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: name + "_rejected_total",
+			Help: "Number of requests rejected by the rate limiter.",
+		}),
+		utilization: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: name + "_utilization",
+			Help: "Fraction of the current window's limit that has been used.",
+		}),
+	}
+	for _, m := range []prometheus.Collector{c.accepted, c.rejected, c.utilization} {
+		if err := reg.Register(m); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// IncAccepted increments <name>_accepted_total.
+func (c *Collector) IncAccepted() { c.accepted.Inc() }
+
+// IncRejected increments <name>_rejected_total.
+func (c *Collector) IncRejected() { c.rejected.Inc() }
+
+// ObserveUtilization sets <name>_utilization.
+func (c *Collector) ObserveUtilization(u float64) { c.utilization.Set(u) }
+
+// Limiter is a ratelimit.RateLimiter that records every decision of the
+// limiter it wraps. It is a ratelimit.Wrapper, so ratelimit.HTTPMiddleware
+// still sends the wrapped limiter's rate limit headers.
+type Limiter struct {
+	ratelimit.RateLimiter
+
+	metrics *Collector
+}
+
+// InstrumentedLimiter wraps rl and registers the metrics of NewCollector
+// with reg. The utilization gauge is only set if rl implements
+// ratelimit.WindowReporter.
+func InstrumentedLimiter(rl ratelimit.RateLimiter, reg prometheus.Registerer, name string) (*Limiter, error) {
+	c, err := NewCollector(reg, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Limiter{RateLimiter: rl, metrics: c}, nil
+}
+
+// Accept asks the wrapped limiter and records the decision.
+func (l *Limiter) Accept() bool {
+	ok, _ := l.AcceptWithReason()
+	return ok
+}
+
+// AcceptWithReason is like Accept but also returns why the wrapped limiter
+// decided as it did, if it can say.
+func (l *Limiter) AcceptWithReason() (bool, ratelimit.Reason) {
+	ok, reason := ratelimit.AcceptWithReason(l.RateLimiter)
+	if ok {
+		l.metrics.IncAccepted()
+	} else {
+		l.metrics.IncRejected()
+	}
+	if wr, isReporter := ratelimit.As[ratelimit.WindowReporter](l.RateLimiter); isReporter {
+		if limit := wr.WindowLimit(); limit > 0 {
+			used := limit - min(wr.Remaining(), limit)
+			l.metrics.ObserveUtilization(float64(used) / float64(limit))
+		}
+	}
+	return ok, reason
+}
+
+// Unwrap returns the wrapped limiter.
+func (l *Limiter) Unwrap() ratelimit.Limiter { return l.RateLimiter }
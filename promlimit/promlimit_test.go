@@ -0,0 +1,149 @@
+package promlimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+	"github.com/govi230/ratelimit/promlimit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentedLimiter(t *testing.T) {
+	fw := ratelimit.NewFixedWindow(time.Hour, 4)
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+	reg := prometheus.NewPedanticRegistry()
+	l, err := promlimit.InstrumentedLimiter(fw, reg, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 6 {
+		l.Accept()
+	}
+	want := `
+# HELP api_accepted_total Number of requests accepted by the rate limiter.
+# TYPE api_accepted_total counter
+api_accepted_total 4
+# HELP api_rejected_total Number of requests rejected by the rate limiter.
+# TYPE api_rejected_total counter
+api_rejected_total 2
+# HELP api_utilization Fraction of the current window's limit that has been used.
+# TYPE api_utilization gauge
+api_utilization 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := promlimit.InstrumentedLimiter(fw, reg, "api"); err == nil {
+		t.Fatal("registering the same metrics twice succeeded")
+	}
+}
+
+func TestCollector(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	c, err := promlimit.NewCollector(reg, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw := &ratelimit.FixedWindow{Window: time.Hour, Limit: 2, Metrics: c}
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+	for range 3 {
+		fw.Accept()
+	}
+	want := `
+# HELP api_accepted_total Number of requests accepted by the rate limiter.
+# TYPE api_accepted_total counter
+api_accepted_total 2
+# HELP api_rejected_total Number of requests rejected by the rate limiter.
+# TYPE api_rejected_total counter
+api_rejected_total 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "api_accepted_total", "api_rejected_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstrumentedLimiterKeepsHeaders(t *testing.T) {
+	fw := ratelimit.NewFixedWindow(time.Hour, 1)
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	l, err := promlimit.InstrumentedLimiter(fw, prometheus.NewPedanticRegistry(), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := ratelimit.HTTPMiddleware(l)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("X-RateLimit-Limit"); got != "1" {
+		t.Fatalf("X-RateLimit-Limit %q, want 1", got)
+	}
+	fw.Stop()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("after Stop: status %d, want 503", rec.Code)
+	}
+}
+
+func TestHTTPMiddlewareRouteLabels(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	fw := ratelimit.NewFixedWindow(time.Hour, 2)
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+	mw, err := promlimit.HTTPMiddleware(fw, reg, "api", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", mw(ok))
+	mux.Handle("GET /orders", mw(ok))
+	for _, path := range []string{"/users/1", "/users/2", "/orders", "/users/3"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	want := `
+# HELP api_http_accepted_total Number of HTTP requests accepted by the rate limiter.
+# TYPE api_http_accepted_total counter
+api_http_accepted_total{route="GET /users/{id}"} 2
+# HELP api_http_rejected_total Number of HTTP requests rejected by the rate limiter.
+# TYPE api_http_rejected_total counter
+api_http_rejected_total{route="GET /orders"} 1
+api_http_rejected_total{route="GET /users/{id}"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A custom routeLabel replaces the pattern.
+	custom, err := promlimit.HTTPMiddleware(ratelimit.NoOp{}, reg, "custom", func(r *http.Request) string {
+		return r.Header.Get("X-Route")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/anything", nil)
+	r.Header.Set("X-Route", "export")
+	custom(ok).ServeHTTP(httptest.NewRecorder(), r)
+	want = `
+# HELP custom_http_accepted_total Number of HTTP requests accepted by the rate limiter.
+# TYPE custom_http_accepted_total counter
+custom_http_accepted_total{route="export"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "custom_http_accepted_total"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,1055 @@
+// Package ratelimit provides simple in-process rate limiters.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter is the least a limiter must do to be used by the middleware and
+// wrappers in this package and its subpackages, which also make use of
+// these optional interfaces when a limiter implements them:
+//
+//   - WindowReporter, to send X-RateLimit-* headers;
+//   - RetryReporter, to say how long a rejected client should wait;
+//   - ReasonAccepter, to tell a stopped limiter from a full one;
+//   - AcceptNer and WaitNer, to count several units at once;
+//   - Waiter, to block instead of rejecting;
+//   - Refunder, to give back units that were not used.
+type Limiter interface {
+	// Accept reports whether a single request may proceed.
+	Accept() bool
+}
+
+// RateLimiter is implemented by every limiter in this package.
+type RateLimiter interface {
+	Limiter
+	// Validate reports whether the limiter is configured correctly.
+	Validate() error
+	// Do validates the configuration and starts the limiter.
+	Do() error
+	// Stop releases any resources held by the limiter.
+	Stop()
+}
+
+// ErrStopped is returned when a limiter is used after Stop.
+var ErrStopped = errors.New("rate limiter stopped")
+
+// ErrExceedsLimit is returned by WaitN when asked for more units than a
+// window can ever hold.
+var ErrExceedsLimit = errors.New("request exceeds rate limit")
+
+var errNotStarted = errors.New("rate limiter not started")
+
+// jitterN returns a random offset in [0, n) for Jitter. Tests replace it to
+// make the offsets predictable.
+var jitterN = rand.Int64N
+
+// Reason says why a limiter accepted or rejected a request.
+type Reason int
+
+const (
+	// ReasonOK means the request was accepted.
+	ReasonOK Reason = iota
+	// ReasonLimitReached means the current window is full.
+	ReasonLimitReached
+	// ReasonStopped means the limiter has been stopped, or was never
+	// started.
+	ReasonStopped
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonOK:
+		return "ok"
+	case ReasonLimitReached:
+		return "limit reached"
+	case ReasonStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// FixedWindow allows at most Limit requests in every window of
+// Duration Unit, e.g. 100 requests per 1 minute.
+//
+// The window may instead be given directly as Window, in which case
+// Duration and Unit are ignored.
+//
+// By default the count is kept in the FixedWindow itself. Setting Store
+// keeps it in the Store under Key instead, so that several limiters, or
+// several processes, can share it. If the Store fails, requests are
+// accepted when FailOpen is set and rejected otherwise, and the error is
+// reported as an EventStoreError.
+//
+// Store is nil rather than a MemoryStore by default because a Store only
+// offers increments: with one, rejected requests still count, AcceptN is
+// not all or nothing, Refund does nothing and every Accept takes the write
+// lock. The built-in counter has none of these limitations.
+//
+// The zero value's methods are safe to call, but it rejects every request
+// until Do. A FixedWindow must not be copied after first use; go vet reports
+// such copies. Use Clone instead.
+type FixedWindow struct {
+	Duration uint64
+	Unit     string
+	Limit    uint64
+	Window   time.Duration
+	Store    Store
+	Key      string
+	FailOpen bool
+
+	// Burst lets a window take up to Burst requests beyond Limit, as a
+	// short-term overflow allowance. It is used only once Limit is reached
+	// and resets with the window like the rest of the count.
+	Burst uint64
+
+	// Warmup, if set, ramps the limit linearly from InitialLimit up to
+	// Limit over that long after Do, to protect cold downstreams.
+	Warmup       time.Duration
+	InitialLimit uint64
+
+	// MinInterval, if set, is the least time allowed between two accepted
+	// requests, on top of the per-window limit. It spreads requests out
+	// instead of letting a whole window's worth through at once.
+	MinInterval time.Duration
+
+	// InitialCount is the number of requests already counted in the first
+	// window, e.g. to start partially used in tests. It must not exceed
+	// Limit.
+	InitialCount uint64
+
+	// Jitter, if set, ends the first window early by a random amount of
+	// up to Jitter, so that limiters started at the same time do not all
+	// reset, and wake their waiters, at the same instant.
+	Jitter time.Duration
+
+	// ResetOnFirstRequest, if set, starts a new window with the first
+	// request after the previous one has ended, instead of keeping windows
+	// aligned to the time Do was called. After an idle gap the next window
+	// therefore runs for a full Window from that request on.
+	ResetOnFirstRequest bool
+
+	// Clock, if set, replaces the system clock, e.g. with a fake one in
+	// tests.
+	Clock Clock
+
+	// OnReject, if set, is called after every rejected request. It runs on
+	// the caller's goroutine outside the limiter's lock, so it may use the
+	// limiter, but it should be fast and must not block.
+	OnReject func()
+
+	// OnFull, if set, is called once per window, after the request that
+	// uses up the last of its limit, including Burst. Like OnReject it
+	// runs outside the limiter's lock.
+	OnFull func()
+
+	// Metrics, if set, is told about every decision. Like OnReject it is
+	// called outside the limiter's lock.
+	Metrics MetricsCollector
+
+	// EventBuffer is the capacity of the channel returned by Events. Events
+	// are only recorded when it is greater than zero.
+	EventBuffer int
+
+	counter     atomic.Uint64 // written under mu, or by compare-and-swap without it
+	mu          sync.RWMutex
+	startedAt   time.Time
+	lastAccept  time.Time
+	reserved    map[int64]uint64 // reservations for future windows by start
+	period      time.Duration
+	windowStart time.Time     // start of the window counter belongs to
+	resets      uint64        // windows ended so far
+	lastReset   time.Time     // end of the latest of those windows
+	finalCount  uint64        // counter when Stop was called
+	window      uint64        // windows begun so far, telling them apart
+	fullWindow  atomic.Uint64 // window+1 of the window OnFull last ran for
+	fast        atomic.Pointer[fastWindow]
+	highWater   uint64        // highest counter before it last went down
+	peakLast    uint64        // highest counter of the previous window
+	saved       *savedState   // loaded before Do, applied by Do
+	resetCh     chan struct{} // closed and replaced to wake Wait callers
+	events      chan Event
+	started     bool
+	stop        bool
+	stopOnce    sync.Once
+}
+
+// NewFixedWindow returns a FixedWindow allowing limit requests per window.
+// The limiter still has to be started with Do.
+func NewFixedWindow(window time.Duration, limit uint64) *FixedWindow {
+	return &FixedWindow{Window: window, Limit: limit}
+}
+
+// Clone returns a new, not yet started FixedWindow with the same
+// configuration as fw, including any changes made by SetLimit and
+// SetWindow, but none of its state. Store and Key are left unset, since
+// clones sharing them would share one counter. Copying a FixedWindow by
+// value is not safe; use Clone to stamp out limiters from a template.
+func (fw *FixedWindow) Clone() *FixedWindow {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return &FixedWindow{
+		Duration:     fw.Duration,
+		Unit:         fw.Unit,
+		Limit:        fw.Limit,
+		Window:       fw.Window,
+		FailOpen:     fw.FailOpen,
+		Burst:        fw.Burst,
+		Warmup:       fw.Warmup,
+		InitialLimit: fw.InitialLimit,
+		MinInterval:  fw.MinInterval,
+		InitialCount: fw.InitialCount,
+		Clock:        fw.Clock,
+		OnReject:     fw.OnReject,
+		OnFull:       fw.OnFull,
+		Metrics:      fw.Metrics,
+		EventBuffer:  fw.EventBuffer,
+		Jitter:       fw.Jitter,
+
+		ResetOnFirstRequest: fw.ResetOnFirstRequest,
+	}
+}
+
+// Validate checks that the window and limit are usable.
+func (fw *FixedWindow) Validate() error {
+	if fw.Limit == 0 {
+		return errors.New("limit must be greater than zero")
+	}
+	if fw.Warmup < 0 {
+		return errors.New("warmup must not be negative")
+	}
+	if fw.Burst > math.MaxUint64-fw.Limit {
+		return errors.New("limit plus burst overflows uint64")
+	}
+	if fw.InitialLimit > fw.Limit {
+		return errors.New("initial limit must not exceed limit")
+	}
+	if fw.MinInterval < 0 {
+		return errors.New("minimum interval must not be negative")
+	}
+	if fw.InitialCount > fw.Limit {
+		return errors.New("initial count must not exceed limit")
+	}
+	if fw.Jitter < 0 {
+		return errors.New("jitter must not be negative")
+	}
+	if fw.Window != 0 {
+		if fw.Window < 0 {
+			return errors.New("window must be greater than zero")
+		}
+		return nil
+	}
+	if fw.Duration == 0 {
+		return errors.New("duration must be greater than zero")
+	}
+	return validateWindow(fw.Duration, fw.Unit)
+}
+
+// Do validates the configuration and starts the first window. Windows are
+// rolled over lazily by the calls that need them, so no goroutine is
+// started.
+//
+// A FixedWindow is not usable until Do has returned successfully, so the
+// usual pattern is to fill in the exported fields and call Do straight away.
+// Calling Do again on a started limiter returns an error.
+func (fw *FixedWindow) Do() error {
+	if err := fw.Validate(); err != nil {
+		return err
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.stop {
+		return ErrStopped
+	}
+	if fw.started {
+		return errors.New("rate limiter already started")
+	}
+	d, err := fw.duration()
+	if err != nil {
+		return err
+	}
+	fw.period = d
+	fw.startedAt = fw.now()
+	fw.windowStart = fw.startedAt
+	if fw.Jitter > 0 {
+		// Shift the window boundaries back rather than forward so the
+		// first window is never longer than the others.
+		offset := time.Duration(jitterN(int64(fw.Jitter))) % fw.period
+		fw.windowStart = fw.windowStart.Add(-offset)
+	}
+	fw.counter.Store(fw.InitialCount)
+	if fw.saved != nil {
+		fw.restoreLocked(*fw.saved, fw.startedAt)
+		fw.saved = nil
+	}
+	fw.resetCh = make(chan struct{})
+	if fw.EventBuffer > 0 {
+		fw.events = make(chan Event, fw.EventBuffer)
+	}
+	fw.started = true
+	fw.publishLocked()
+	return nil
+}
+
+// Accept reports whether the request fits in the current window and, if so,
+// counts it.
+func (fw *FixedWindow) Accept() bool {
+	ok, _ := fw.AcceptWithReason()
+	return ok
+}
+
+// AcceptWithReason is like Accept but also says why a request was rejected.
+func (fw *FixedWindow) AcceptWithReason() (bool, Reason) {
+	return fw.acceptN(1, fw.now())
+}
+
+// TryAccept is like Accept but returns ErrStopped if the limiter has been
+// stopped, so that callers can tell a shut-down limiter from a full one.
+func (fw *FixedWindow) TryAccept() (bool, error) {
+	ok, reason := fw.acceptN(1, fw.now())
+	if reason == ReasonStopped {
+		return false, ErrStopped
+	}
+	return ok, nil
+}
+
+// AcceptCtx is like TryAccept but first checks ctx, returning its error
+// without using up a slot if ctx is already done.
+func (fw *FixedWindow) AcceptCtx(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return fw.TryAccept()
+}
+
+// AcceptN reports whether n units fit in the current window and, if so,
+// counts all of them. Either all n units are counted or none are, so a
+// request that only partially fits leaves the counter untouched.
+//
+// AcceptN(0) always succeeds on a running limiter, and a request for more
+// than Limit units never does.
+func (fw *FixedWindow) AcceptN(n uint64) bool {
+	ok, _ := fw.acceptN(n, fw.now())
+	return ok
+}
+
+// AcceptUpTo counts as many of n units as fit in the current window and
+// returns how many that was, from zero if the window is full up to n.
+// Unlike AcceptN a request that only partially fits is partially accepted.
+// With a Store, which cannot hand back part of an increment, it is all or
+// nothing like AcceptN.
+func (fw *FixedWindow) AcceptUpTo(n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+	now := fw.now()
+	fw.mu.Lock()
+	fw.advanceLocked(now)
+	var got, filled uint64
+	if fw.started && !fw.stop {
+		got = fw.acceptUpToLocked(n, now)
+	}
+	if got > 0 {
+		filled = fw.filledLocked(now)
+	}
+	fw.recordLocked(got > 0, now)
+	fw.mu.Unlock()
+	fw.decided(got > 0, filled)
+	return got
+}
+
+// acceptUpToLocked counts up to n units and returns how many it counted.
+// fw.mu must be held.
+func (fw *FixedWindow) acceptUpToLocked(n uint64, now time.Time) uint64 {
+	if fw.Store != nil {
+		if fw.acceptLocked(n, now) {
+			return n
+		}
+		return 0
+	}
+	if fw.intervalLeftLocked(now) > 0 {
+		return 0
+	}
+	limit := fw.limitAt(now)
+	for {
+		c := fw.counter.Load()
+		if c >= limit {
+			return 0
+		}
+		got := min(n, limit-c)
+		if fw.counter.CompareAndSwap(c, c+got) {
+			fw.lastAccept = now
+			return got
+		}
+	}
+}
+
+// AcceptNWithReason is like AcceptN but also says why a request was
+// rejected.
+func (fw *FixedWindow) AcceptNWithReason(n uint64) (bool, Reason) {
+	return fw.acceptN(n, fw.now())
+}
+
+// AllowAt is like Accept but decides as if the current time were t, so
+// tests can step through windows without waiting for them.
+func (fw *FixedWindow) AllowAt(t time.Time) bool {
+	ok, _ := fw.acceptN(1, t)
+	return ok
+}
+
+func (fw *FixedWindow) acceptN(n uint64, now time.Time) (bool, Reason) {
+	if ok, filled, handled := fw.acceptFast(n, now); handled {
+		fw.decided(ok, filled)
+		if !ok {
+			return false, ReasonLimitReached
+		}
+		return true, ReasonOK
+	}
+	fw.mu.Lock()
+	fw.advanceLocked(now)
+	reason := ReasonOK
+	switch {
+	case !fw.started || fw.stop:
+		reason = ReasonStopped
+	case n > 0 && !fw.acceptLocked(n, now):
+		reason = ReasonLimitReached
+	}
+	ok := reason == ReasonOK
+	var filled uint64
+	if ok {
+		filled = fw.filledLocked(now)
+	}
+	fw.recordLocked(ok, now)
+	fw.mu.Unlock()
+	fw.decided(ok, filled)
+	return ok, reason
+}
+
+// fastWindow is what acceptFast needs to know about the current window. It
+// is never modified, only replaced by publishLocked.
+type fastWindow struct {
+	window uint64    // fw.window
+	end    time.Time // when the window ends
+	warm   time.Time // when Warmup ends, before which the limit changes
+	limit  uint64    // Limit plus Burst
+}
+
+// publishLocked makes the current window's state available to acceptFast,
+// or disables acceptFast when deciding needs more than the counter: when
+// the limiter is not running, or MinInterval, a Store or events are used.
+// It must be called after anything in fastWindow changes. fw.mu must be
+// held.
+func (fw *FixedWindow) publishLocked() {
+	if !fw.started || fw.stop || fw.Store != nil || fw.MinInterval > 0 || fw.events != nil {
+		fw.fast.Store(nil)
+		return
+	}
+	f := &fastWindow{
+		window: fw.window,
+		end:    fw.windowStart.Add(fw.period),
+		limit:  fw.Limit + fw.Burst,
+	}
+	if fw.Warmup > 0 {
+		f.warm = fw.startedAt.Add(fw.Warmup)
+	}
+	fw.fast.Store(f)
+}
+
+// acceptFast counts n with a compare-and-swap on the counter without taking
+// fw.mu, so concurrent callers do not serialise on the common path. It
+// reports handled false if the slow path under the write lock is needed
+// instead: when the window has ended and must be advanced, while warming
+// up, or when publishLocked has disabled it.
+//
+// A request racing with the start of the next window is counted in
+// whichever window the counter belongs to when the swap succeeds, which
+// keeps every window within its limit.
+func (fw *FixedWindow) acceptFast(n uint64, now time.Time) (ok bool, filled uint64, handled bool) {
+	f := fw.fast.Load()
+	if f == nil || n == 0 || !now.Before(f.end) || now.Before(f.warm) {
+		return false, 0, false
+	}
+	c, ok := fw.add(n, f.limit)
+	if ok && c >= f.limit {
+		filled = f.window + 1
+	}
+	return ok, filled, true
+}
+
+// add counts n units if the counter stays within limit and returns the new
+// count. It is safe against acceptFast, so it may be used under fw.mu
+// while lock-free callers are counting too.
+func (fw *FixedWindow) add(n, limit uint64) (uint64, bool) {
+	for {
+		c := fw.counter.Load()
+		if c >= limit || n > limit-c {
+			return c, false
+		}
+		if fw.counter.CompareAndSwap(c, c+n) {
+			return c + n, true
+		}
+	}
+}
+
+// sub takes up to n units off the counter without losing concurrent adds.
+func (fw *FixedWindow) sub(n uint64) {
+	for {
+		c := fw.counter.Load()
+		if fw.counter.CompareAndSwap(c, c-min(n, c)) {
+			return
+		}
+	}
+}
+
+// advanceLocked starts a new window if the current one has ended by now.
+// Windows stay aligned to the time Do was called unless ResetOnFirstRequest
+// is set. fw.mu must be held.
+func (fw *FixedWindow) advanceLocked(now time.Time) {
+	if !fw.started || fw.stop {
+		return
+	}
+	start := fw.windowStartAt(now)
+	if !start.After(fw.windowStart) {
+		return
+	}
+	ended := fw.windowsEndedLocked(start)
+	peak := fw.peakLocked()
+	if ended > 1 {
+		// The window just before start saw no requests.
+		peak = 0
+	}
+	fw.startWindowLocked(start, now, ended, peak)
+}
+
+// startWindowLocked makes the window beginning at start the current one,
+// after ended windows of which the last peaked at peak. fw.mu must be held.
+func (fw *FixedWindow) startWindowLocked(start, now time.Time, ended, peak uint64) {
+	fw.resets += ended
+	fw.lastReset = start
+	fw.windowStart = start
+	fw.clear()
+	fw.peakLast, fw.highWater = peak, 0
+	fw.counter.Store(fw.takeReservedLocked(start))
+	fw.publishLocked()
+	fw.emit(Event{Type: EventWindowReset, Time: now, Peak: peak})
+}
+
+// windowStartAt returns the start of the window containing now. With
+// ResetOnFirstRequest that is now itself once the stored window has ended,
+// since a request at now would start the next one. fw.mu must be held.
+func (fw *FixedWindow) windowStartAt(now time.Time) time.Time {
+	elapsed := now.Sub(fw.windowStart)
+	if elapsed < fw.period {
+		return fw.windowStart
+	}
+	if fw.ResetOnFirstRequest {
+		return now
+	}
+	return fw.windowStart.Add(elapsed / fw.period * fw.period)
+}
+
+// windowsEndedLocked returns how many windows end when moving from the
+// stored window to the one beginning at start. fw.mu must be held.
+func (fw *FixedWindow) windowsEndedLocked(start time.Time) uint64 {
+	if fw.ResetOnFirstRequest {
+		return 1
+	}
+	return uint64(start.Sub(fw.windowStart) / fw.period)
+}
+
+// counterAt returns the count of the window containing now, which is zero
+// if the window stored in fw has already ended. fw.mu must be held.
+func (fw *FixedWindow) counterAt(now time.Time) uint64 {
+	if fw.started && !fw.windowStartAt(now).Equal(fw.windowStart) {
+		return 0
+	}
+	return fw.counter.Load()
+}
+
+// decided runs the OnReject and OnFull hooks and reports to Metrics.
+// filled is window+1 of the window the request filled up, or zero if it
+// left room. fw.mu must not be held.
+func (fw *FixedWindow) decided(accepted bool, filled uint64) {
+	if !accepted && fw.OnReject != nil {
+		fw.OnReject()
+	}
+	if filled > 0 && fw.OnFull != nil && fw.markFull(filled) {
+		fw.OnFull()
+	}
+	if fw.Metrics != nil {
+		fw.record(accepted)
+	}
+}
+
+// filledLocked returns window+1 if the current window is full at now, or
+// zero. fw.mu must be held.
+func (fw *FixedWindow) filledLocked(now time.Time) uint64 {
+	if fw.counterAt(now) < fw.limitAt(now) {
+		return 0
+	}
+	return fw.window + 1
+}
+
+// markFull reports whether OnFull has yet to run for filled, a window+1,
+// and marks it as run. Windows only move forward, so OnFull is not run for
+// one that ended while the request was being decided.
+func (fw *FixedWindow) markFull(filled uint64) bool {
+	for {
+		last := fw.fullWindow.Load()
+		if last >= filled {
+			return false
+		}
+		if fw.fullWindow.CompareAndSwap(last, filled) {
+			return true
+		}
+	}
+}
+
+// acceptLocked counts n units if they fit in the window. fw.mu must be held.
+func (fw *FixedWindow) acceptLocked(n uint64, now time.Time) bool {
+	if fw.intervalLeftLocked(now) > 0 || !fw.countLocked(n, now) {
+		return false
+	}
+	fw.lastAccept = now
+	return true
+}
+
+// countLocked adds n to the window's count if it fits. fw.mu must be held.
+func (fw *FixedWindow) countLocked(n uint64, now time.Time) bool {
+	if fw.Store != nil {
+		// The key lasts as long as what is left of the window, so that it
+		// ends when the limiter starts its next one.
+		count, err := fw.Store.Incr(fw.Key, n, fw.resetInLocked(now))
+		if err != nil {
+			fw.emit(Event{Type: EventStoreError, Time: now, Err: err})
+			return fw.FailOpen
+		}
+		fw.counter.Store(count)
+		return count <= fw.limitAt(now)
+	}
+	_, ok := fw.add(n, fw.limitAt(now))
+	return ok
+}
+
+// Refund gives back n units accepted in the current window. It has no
+// effect on a limiter backed by a Store, since Stores cannot decrement, and
+// it does not undo the wait for MinInterval.
+func (fw *FixedWindow) Refund(n uint64) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.Store != nil {
+		return
+	}
+	fw.highWater = fw.peakLocked()
+	fw.sub(n)
+	fw.wake()
+}
+
+// Wait blocks until a request fits in the window and counts it, or until
+// ctx is done, in which case it returns ctx.Err(). It returns ErrStopped if
+// the limiter has been stopped.
+func (fw *FixedWindow) Wait(ctx context.Context) error {
+	return fw.WaitN(ctx, 1)
+}
+
+// WaitN is like Wait but for n units at once, which are counted together
+// once they all fit in a window. It returns ErrExceedsLimit straight away if
+// n is more than Limit plus Burst, since such a request could never be
+// satisfied.
+func (fw *FixedWindow) WaitN(ctx context.Context, n uint64) error {
+	for {
+		fw.mu.Lock()
+		if fw.stop {
+			fw.mu.Unlock()
+			return ErrStopped
+		}
+		if !fw.started {
+			fw.mu.Unlock()
+			return errNotStarted
+		}
+		if n > fw.Limit+fw.Burst {
+			fw.mu.Unlock()
+			return ErrExceedsLimit
+		}
+		now := fw.now()
+		fw.advanceLocked(now)
+		if fw.acceptLocked(n, now) {
+			filled := fw.filledLocked(now)
+			fw.recordLocked(true, now)
+			fw.mu.Unlock()
+			fw.decided(true, filled)
+			return nil
+		}
+		wake := fw.resetCh
+		d := fw.retryAfterLocked(now)
+		if d <= 0 {
+			// Rejected for a reason that does not clear by itself, such as
+			// a Store error or n not fitting in what is left of the window;
+			// try again in the next window.
+			d = fw.resetInLocked(now)
+		}
+		timer := time.NewTimer(d)
+		fw.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Counter returns the number of requests accepted in the current window.
+func (fw *FixedWindow) Counter() uint64 {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.counterAt(fw.now())
+}
+
+// Remaining returns how many more requests fit in the current window.
+func (fw *FixedWindow) Remaining() uint64 {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	now := fw.now()
+	counter, limit := fw.counterAt(now), fw.limitAt(now)
+	if counter >= limit {
+		return 0
+	}
+	return limit - counter
+}
+
+// Utilization returns the fraction of the current window's limit, including
+// Burst, that has been used, from 0 to 1.
+func (fw *FixedWindow) Utilization() float64 {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	now := fw.now()
+	limit := fw.limitAt(now)
+	if limit == 0 {
+		return 0
+	}
+	return min(float64(fw.counterAt(now))/float64(limit), 1)
+}
+
+// WindowLimit returns the number of requests allowed per window, including
+// Burst and taking any warmup into account.
+func (fw *FixedWindow) WindowLimit() uint64 {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.limitAt(fw.now())
+}
+
+// RatePerSecond returns Limit divided by the window length in seconds, so
+// that limiters configured with different units can be compared on the same
+// scale: 3600 per hour and 60 per minute are both 1. Burst and warmup are
+// not included. It returns 0 if the window is not valid.
+func (fw *FixedWindow) RatePerSecond() float64 {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	d, err := fw.duration()
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return float64(fw.Limit) / d.Seconds()
+}
+
+// ResetIn returns the time left until the current window ends.
+func (fw *FixedWindow) ResetIn() time.Duration {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.resetInLocked(fw.now())
+}
+
+// NextResetTime returns when the current window ends and the next one
+// starts, so that deferred work can be scheduled for then instead of
+// polling RetryAfter. It returns the zero time before Do.
+func (fw *FixedWindow) NextResetTime() time.Time {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	if !fw.started {
+		return time.Time{}
+	}
+	return fw.windowStartAt(fw.now()).Add(fw.period)
+}
+
+// resetInLocked returns the time left at now in the window containing it.
+// fw.mu must be held.
+func (fw *FixedWindow) resetInLocked(now time.Time) time.Duration {
+	if !fw.started {
+		return 0
+	}
+	return fw.windowStartAt(now).Add(fw.period).Sub(now)
+}
+
+// RetryAfter returns how long until a request can be accepted again: zero
+// if one would be accepted now, otherwise the time until the window ends or
+// MinInterval has passed.
+func (fw *FixedWindow) RetryAfter() time.Duration {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.retryAfterLocked(fw.now())
+}
+
+// retryAfterLocked returns how long until a request could be accepted.
+// fw.mu must be held.
+func (fw *FixedWindow) retryAfterLocked(now time.Time) time.Duration {
+	if fw.counterAt(now) >= fw.limitAt(now) {
+		return fw.resetInLocked(now)
+	}
+	return fw.intervalLeftLocked(now)
+}
+
+// intervalLeftLocked returns how much of MinInterval is left since the last
+// accepted request. fw.mu must be held.
+func (fw *FixedWindow) intervalLeftLocked(now time.Time) time.Duration {
+	if fw.MinInterval <= 0 || fw.lastAccept.IsZero() {
+		return 0
+	}
+	return max(fw.lastAccept.Add(fw.MinInterval).Sub(now), 0)
+}
+
+// Reset clears the counter without moving the window boundaries, so the next
+// window still starts on time. It may be called before Do.
+func (fw *FixedWindow) Reset() {
+	if fw.Store != nil {
+		fw.Store.Reset(fw.Key)
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.clear()
+	fw.publishLocked()
+}
+
+// Flush ends the current window now and starts a new one, so that, unlike
+// after Reset, the next window starts a full window from now. Reservations
+// for later windows are dropped, since those windows move. Before Do it is
+// the same as Reset.
+func (fw *FixedWindow) Flush() {
+	if fw.Store != nil {
+		fw.Store.Reset(fw.Key)
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if !fw.started {
+		fw.clear()
+		return
+	}
+	if fw.stop {
+		return
+	}
+	now := fw.now()
+	fw.advanceLocked(now)
+	fw.reserved = nil
+	fw.startWindowLocked(now, now, 1, fw.peakLocked())
+}
+
+// SetLimit changes the number of requests allowed per window without
+// restarting the limiter. If the window already holds more than limit
+// requests, Accept rejects until the next reset.
+func (fw *FixedWindow) SetLimit(limit uint64) error {
+	if limit == 0 {
+		return errors.New("limit must be greater than zero")
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.Burst > math.MaxUint64-limit {
+		return errors.New("limit plus burst overflows uint64")
+	}
+	fw.Limit = limit
+	fw.publishLocked()
+	fw.wake()
+	return nil
+}
+
+// SetWindow changes the window length without restarting the limiter. The
+// count of the current window carries over into a new window of length d
+// starting now. Reservations made for later windows are no longer counted.
+func (fw *FixedWindow) SetWindow(d time.Duration) error {
+	if d <= 0 {
+		return errors.New("window must be greater than zero")
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.Window = d
+	fw.setPeriodLocked(d)
+	return nil
+}
+
+// SetUnit changes the unit of the window, e.g. from "minute" to "second",
+// keeping Duration. As with SetWindow the current count is kept and a
+// window of the new length starts now. An invalid unit is an error and
+// leaves the limiter unchanged. If Window is set it takes precedence, so
+// only Unit is updated.
+func (fw *FixedWindow) SetUnit(unit string) error {
+	if err := validateWindow(fw.Duration, unit); err != nil {
+		return err
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.Unit = unit
+	if fw.Window == 0 {
+		u, _ := unitDuration(unit)
+		fw.setPeriodLocked(time.Duration(fw.Duration) * u)
+	}
+	return nil
+}
+
+// setPeriodLocked starts a window of length d now, if the limiter is
+// running. Reservations for later windows are dropped, since those windows
+// move. fw.mu must be held.
+func (fw *FixedWindow) setPeriodLocked(d time.Duration) {
+	if !fw.started {
+		return
+	}
+	now := fw.now()
+	fw.advanceLocked(now)
+	fw.period = d
+	fw.windowStart = now
+	fw.reserved = nil
+	fw.publishLocked()
+	fw.wake()
+}
+
+// Stop makes Accept reject every request and closes the Events channel.
+// Callers blocked in Wait return ErrStopped straight away.
+//
+// A FixedWindow holds no goroutines or timers, so Stop is not needed to free
+// resources. It may be called at any point, including before Do, and any
+// number of times; only the first call has an effect.
+func (fw *FixedWindow) Stop() {
+	fw.stopOnce.Do(fw.stopNow)
+}
+
+func (fw *FixedWindow) stopNow() {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.events != nil {
+		close(fw.events)
+	}
+	fw.finalCount = fw.counterAt(fw.now())
+	fw.stop = true
+	fw.publishLocked()
+	// Wake every Wait caller so that it sees the stop and returns
+	// ErrStopped instead of sleeping until its context ends.
+	fw.wake()
+}
+
+// IsRunning reports whether Do has been called successfully and Stop has
+// not.
+func (fw *FixedWindow) IsRunning() bool {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.started && !fw.stop
+}
+
+// FinalCount returns the number of requests counted in the window that was
+// in progress when Stop was called, e.g. to reconcile billing at shutdown.
+// It returns zero if the limiter has not been stopped.
+func (fw *FixedWindow) FinalCount() uint64 {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.finalCount
+}
+
+// PeakLastWindow returns the highest count reached in the previous window,
+// which can be higher than the count it ended with if requests were
+// refunded or the limiter was Reset. It is zero before the first window has
+// ended.
+func (fw *FixedWindow) PeakLastWindow() uint64 {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	if !fw.started || fw.stop {
+		return fw.peakLast
+	}
+	// Windows that ended since the last call to advanceLocked.
+	switch start := fw.windowStartAt(fw.now()); {
+	case !start.After(fw.windowStart):
+		return fw.peakLast
+	case fw.windowsEndedLocked(start) == 1:
+		return fw.peakLocked()
+	default:
+		return 0
+	}
+}
+
+// peakLocked returns the highest count of the current window so far. The
+// counter only goes down under the write lock, which records highWater
+// first, so the lock-free path in acceptFast need not track it. fw.mu must
+// be held.
+func (fw *FixedWindow) peakLocked() uint64 {
+	return max(fw.highWater, fw.counter.Load())
+}
+
+// clear zeroes the counter, which lets OnFull run again, and wakes any Wait
+// callers. fw.mu must be held.
+func (fw *FixedWindow) clear() {
+	fw.highWater = fw.peakLocked()
+	fw.counter.Store(0)
+	fw.window++
+	fw.wake()
+}
+
+// wake tells Wait callers to check for room again. fw.mu must be held.
+func (fw *FixedWindow) wake() {
+	if fw.resetCh != nil {
+		close(fw.resetCh)
+		fw.resetCh = make(chan struct{})
+	}
+}
+
+func (fw *FixedWindow) duration() (time.Duration, error) {
+	if fw.Window != 0 {
+		return fw.Window, nil
+	}
+	if err := validateUnit(fw.Unit); err != nil {
+		return 0, err
+	}
+	u, _ := unitDuration(fw.Unit)
+	return time.Duration(fw.Duration) * u, nil
+}
+
+// unitDuration returns the length of one unit, e.g. time.Minute for "minute".
+func unitDuration(unit string) (time.Duration, bool) {
+	switch unit {
+	case "millisecond":
+		return time.Millisecond, true
+	case "second":
+		return time.Second, true
+	case "minute":
+		return time.Minute, true
+	case "hour":
+		return time.Hour, true
+	case "day":
+		return 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+func validateUnit(unit string) error {
+	if _, ok := unitDuration(unit); !ok {
+		return fmt.Errorf("unsupported unit %q: must be one of millisecond, second, minute, hour, day", unit)
+	}
+	return nil
+}
+
+// validateWindow checks the unit and that duration units fit in a
+// time.Duration.
+func validateWindow(duration uint64, unit string) error {
+	if err := validateUnit(unit); err != nil {
+		return err
+	}
+	if u, _ := unitDuration(unit); duration > uint64(math.MaxInt64/u) {
+		return fmt.Errorf("duration %d %s is longer than a time.Duration can hold", duration, unit)
+	}
+	return nil
+}
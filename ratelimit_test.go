@@ -0,0 +1,1088 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// startFixedWindow starts fw and stops it when the test ends.
+func startFixedWindow(t *testing.T, fw *ratelimit.FixedWindow) *ratelimit.FixedWindow {
+	t.Helper()
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fw.Stop)
+	return fw
+}
+
+// TestConcurrentAccept is meant to be run with -race: windows roll over while
+// many goroutines accept, read the counter and finally stop the limiter.
+func TestConcurrentAccept(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Millisecond, 5))
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 2000 {
+				fw.Accept()
+				if c := fw.Counter(); c > 5 {
+					t.Errorf("counter %d over limit 5", c)
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(5 * time.Millisecond)
+		fw.Stop()
+	}()
+	wg.Wait()
+	if fw.Accept() {
+		t.Fatal("accepted after Stop")
+	}
+}
+
+// TestConcurrentCounter reads the counter while other goroutines accept and
+// reset it. It is meant to be run with -race.
+func TestConcurrentCounter(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1000))
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 1000 {
+				switch {
+				case i < 4:
+					fw.Accept()
+				case i == 4 && j%100 == 0:
+					fw.Reset()
+				default:
+					if c := fw.Counter(); c > 1000 {
+						t.Errorf("counter %d over limit 1000", c)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCounterBeforeDo(t *testing.T) {
+	var zero ratelimit.FixedWindow
+	if got := zero.Counter(); got != 0 {
+		t.Fatalf("zero value counter %d, want 0", got)
+	}
+	fw := ratelimit.NewFixedWindow(time.Minute, 5)
+	if got := fw.Counter(); got != 0 {
+		t.Fatalf("counter %d before Do, want 0", got)
+	}
+	fw.Stop()
+	if got := fw.Counter(); got != 0 {
+		t.Fatalf("counter %d after Stop before Do, want 0", got)
+	}
+}
+
+func TestConcurrentAcceptCountsExactly(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1000))
+	var wg sync.WaitGroup
+	var accepted atomic.Int64
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if fw.Accept() {
+					accepted.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := accepted.Load(); got != 1000 {
+		t.Fatalf("accepted %d of 5000 requests, want 1000", got)
+	}
+	if got := fw.Counter(); got != 1000 {
+		t.Fatalf("counter %d, want 1000", got)
+	}
+}
+
+// TestConcurrentAcceptAcrossWindows races the lock-free path against window
+// rollovers, Refund and SetLimit, which all need the write lock.
+func TestConcurrentAcceptAcrossWindows(t *testing.T) {
+	const limit = 10
+	clock := newFakeClock()
+	var full atomic.Int64
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: limit, Clock: clock, OnFull: func() { full.Add(1) }})
+	var wg sync.WaitGroup
+	var accepted atomic.Int64
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 2000 {
+				if !fw.Accept() {
+					continue
+				}
+				accepted.Add(1)
+				if i == 0 {
+					fw.Refund(1)
+					accepted.Add(-1)
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	windows := int64(1)
+	for running := true; running; windows++ {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		if c := fw.Counter(); c > limit {
+			t.Errorf("counter %d over the limit of %d", c, limit)
+		}
+		fw.SetLimit(limit)
+		clock.Add(time.Minute)
+		runtime.Gosched()
+	}
+	if got, max := accepted.Load(), limit*windows; got > max {
+		t.Fatalf("accepted %d in %d windows, want at most %d", got, windows, max)
+	}
+	if got := full.Load(); got > windows {
+		t.Fatalf("OnFull ran %d times in %d windows", got, windows)
+	}
+}
+
+func TestStopBeforeDo(t *testing.T) {
+	var zero ratelimit.FixedWindow
+	zero.Stop()
+	zero.Stop()
+
+	fw := ratelimit.NewFixedWindow(time.Second, 1)
+	fw.Stop()
+	if err := fw.Do(); err != ratelimit.ErrStopped {
+		t.Fatalf("Do after Stop returned %v, want ErrStopped", err)
+	}
+	if ok, err := fw.TryAccept(); ok || err != ratelimit.ErrStopped {
+		t.Fatalf("TryAccept after Stop before Do = %v, %v, want false, ErrStopped", ok, err)
+	}
+	if err := fw.Wait(context.Background()); err != ratelimit.ErrStopped {
+		t.Fatalf("Wait after Stop before Do returned %v, want ErrStopped", err)
+	}
+	if err := fw.WaitN(context.Background(), 2); err != ratelimit.ErrStopped {
+		t.Fatalf("WaitN after Stop before Do returned %v, want ErrStopped", err)
+	}
+	if ok, reason := fw.AcceptWithReason(); ok || reason != ratelimit.ReasonStopped {
+		t.Fatalf("AcceptWithReason after Stop before Do = %v, %v, want false, %v", ok, reason, ratelimit.ReasonStopped)
+	}
+}
+
+func TestDoTwice(t *testing.T) {
+	before := runtime.NumGoroutine()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "second", Limit: 5})
+	if err := fw.Do(); err == nil {
+		t.Fatal("second Do succeeded")
+	}
+	// Windows are rolled over lazily, so neither call leaves a goroutine
+	// behind.
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("%d goroutines after Do, want %d", n, before)
+	}
+	if !fw.Accept() {
+		t.Fatal("limiter unusable after second Do")
+	}
+}
+
+func TestAcceptN(t *testing.T) {
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 5})
+	if !fw.AcceptN(3) {
+		t.Fatal("AcceptN(3) rejected on an empty window")
+	}
+	// Only 2 of 3 fit: nothing is counted.
+	if fw.AcceptN(3) {
+		t.Fatal("AcceptN(3) accepted with 2 left")
+	}
+	if got := fw.Counter(); got != 3 {
+		t.Fatalf("counter %d after partial-fit rejection, want 3", got)
+	}
+	if !fw.AcceptN(0) {
+		t.Fatal("AcceptN(0) rejected")
+	}
+	if got := fw.Counter(); got != 3 {
+		t.Fatalf("counter %d after AcceptN(0), want 3", got)
+	}
+	if !fw.AcceptN(2) || fw.Counter() != 5 {
+		t.Fatalf("AcceptN(2) did not fill the window, counter %d", fw.Counter())
+	}
+	if !fw.AcceptN(0) {
+		t.Fatal("AcceptN(0) rejected on a full window")
+	}
+
+	fresh := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 5})
+	if fresh.AcceptN(6) {
+		t.Fatal("AcceptN accepted more than Limit")
+	}
+	if got := fresh.Counter(); got != 0 {
+		t.Fatalf("counter %d after AcceptN(6), want 0", got)
+	}
+}
+
+func TestWaitAfterReset(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(50*time.Millisecond, 1))
+	if !fw.Accept() {
+		t.Fatal("first request rejected")
+	}
+	reset := fw.NextResetTime()
+	if err := fw.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if now := time.Now(); now.Before(reset) {
+		t.Fatalf("Wait returned %v before the window reset", reset.Sub(now))
+	}
+	if fw.Counter() != 1 {
+		t.Fatalf("counter %d after Wait, want 1", fw.Counter())
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	fw.Accept()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := fw.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Wait returned %v, want context.DeadlineExceeded", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := fw.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait returned %v, want context.Canceled", err)
+	}
+	if fw.Counter() != 1 {
+		t.Fatalf("counter %d after cancelled Waits, want 1", fw.Counter())
+	}
+}
+
+// fakeClock is a Clock that only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestRemainingAroundReset(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 3, Clock: clock})
+	if got := fw.Remaining(); got != 3 {
+		t.Fatalf("remaining %d on a new window, want 3", got)
+	}
+	fw.AcceptN(2)
+	clock.Add(time.Minute - time.Nanosecond)
+	if got := fw.Remaining(); got != 1 {
+		t.Fatalf("remaining %d just before the reset, want 1", got)
+	}
+	fw.Accept()
+	if got := fw.Remaining(); got != 0 {
+		t.Fatalf("remaining %d on a full window, want 0", got)
+	}
+	clock.Add(time.Nanosecond)
+	if got := fw.Remaining(); got != 3 {
+		t.Fatalf("remaining %d just after the reset, want 3", got)
+	}
+	fw.Accept()
+	if got := fw.Remaining(); got != 2 {
+		t.Fatalf("remaining %d after one request in the new window, want 2", got)
+	}
+}
+
+func TestReset(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 5, Clock: clock})
+	fw.AcceptN(4)
+	clock.Add(20 * time.Second)
+	fw.Reset()
+	if got := fw.Counter(); got != 0 {
+		t.Fatalf("counter %d after Reset, want 0", got)
+	}
+	if !fw.AcceptN(5) {
+		t.Fatal("full capacity not available after Reset")
+	}
+	// The window boundaries do not move.
+	if got := fw.ResetIn(); got != 40*time.Second {
+		t.Fatalf("window resets in %v after Reset, want 40s", got)
+	}
+
+	var unstarted ratelimit.FixedWindow
+	unstarted.Reset()
+}
+
+func TestWindowConfiguration(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fw   *ratelimit.FixedWindow
+		want time.Duration
+	}{
+		{"duration and unit", &ratelimit.FixedWindow{Duration: 2, Unit: "minute", Limit: 1}, 2 * time.Minute},
+		{"window", &ratelimit.FixedWindow{Window: 90 * time.Second, Limit: 1}, 90 * time.Second},
+		{"window wins over duration and unit", &ratelimit.FixedWindow{Window: 5 * time.Second, Duration: 1, Unit: "hour", Limit: 1}, 5 * time.Second},
+		{"window ignores a bad unit", &ratelimit.FixedWindow{Window: time.Second, Unit: "fortnight", Limit: 1}, time.Second},
+		{"NewFixedWindow", ratelimit.NewFixedWindow(3*time.Second, 1), 3 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fw.Clock = newFakeClock()
+			startFixedWindow(t, tc.fw)
+			if got := tc.fw.ResetIn(); got != tc.want {
+				t.Fatalf("window of %v, want %v", got, tc.want)
+			}
+		})
+	}
+	if err := (&ratelimit.FixedWindow{Window: -time.Second, Limit: 1}).Validate(); err == nil {
+		t.Fatal("negative window accepted")
+	}
+}
+
+func TestInvalidUnit(t *testing.T) {
+	for _, unit := range []string{"", "fortnight", "Minute", "seconds"} {
+		fw := &ratelimit.FixedWindow{Duration: 1, Unit: unit, Limit: 1}
+		err := fw.Do()
+		if err == nil {
+			t.Fatalf("Do succeeded with unit %q", unit)
+		}
+		if want := fmt.Sprintf("unsupported unit %q", unit); !strings.HasPrefix(err.Error(), want) {
+			t.Fatalf("Do error %q, want it to start with %q", err, want)
+		}
+		// The limiter was never started, which must not make Stop panic.
+		fw.Stop()
+	}
+}
+
+func TestUnits(t *testing.T) {
+	for unit, want := range map[string]time.Duration{
+		"millisecond": 250 * time.Millisecond,
+		"second":      250 * time.Second,
+		"minute":      250 * time.Minute,
+		"hour":        250 * time.Hour,
+		"day":         250 * 24 * time.Hour,
+	} {
+		fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 250, Unit: unit, Limit: 1, Clock: newFakeClock()})
+		if got := fw.ResetIn(); got != want {
+			t.Errorf("250 %s is a window of %v, want %v", unit, got, want)
+		}
+	}
+	err := (&ratelimit.FixedWindow{Duration: 1, Unit: "week", Limit: 1}).Validate()
+	if err == nil || !strings.Contains(err.Error(), "millisecond, second, minute, hour, day") {
+		t.Fatalf("error %v does not list the supported units", err)
+	}
+}
+
+func TestTryAccept(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	if ok, err := fw.TryAccept(); !ok || err != nil {
+		t.Fatalf("TryAccept = %v, %v on an empty window, want true, nil", ok, err)
+	}
+	if ok, err := fw.TryAccept(); ok || err != nil {
+		t.Fatalf("TryAccept = %v, %v on a full window, want false, nil", ok, err)
+	}
+	fw.Stop()
+	for range 2 {
+		if ok, err := fw.TryAccept(); ok || err != ratelimit.ErrStopped {
+			t.Fatalf("TryAccept = %v, %v after Stop, want false, ErrStopped", ok, err)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 2, Clock: clock})
+	if got := fw.RetryAfter(); got != 0 {
+		t.Fatalf("RetryAfter %v with room in the window, want 0", got)
+	}
+	fw.AcceptN(2)
+	for _, step := range []struct {
+		advance, want time.Duration
+	}{
+		{0, time.Minute},
+		{15 * time.Second, 45 * time.Second},
+		{30 * time.Second, 15 * time.Second},
+		{15*time.Second - time.Millisecond, time.Millisecond},
+		// The next window has room again.
+		{time.Millisecond, 0},
+	} {
+		clock.Add(step.advance)
+		if got := fw.RetryAfter(); got != step.want {
+			t.Fatalf("RetryAfter %v, want %v", got, step.want)
+		}
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 10, Clock: clock})
+	fw.AcceptN(5)
+	if err := fw.SetLimit(3); err != nil {
+		t.Fatal(err)
+	}
+	if fw.Accept() {
+		t.Fatal("accepted with the count already over the lowered limit")
+	}
+	if got := fw.Remaining(); got != 0 {
+		t.Fatalf("remaining %d, want 0", got)
+	}
+	clock.Add(time.Minute)
+	if !fw.AcceptN(3) || fw.Accept() {
+		t.Fatal("the next window does not allow exactly the new limit")
+	}
+	if err := fw.SetLimit(0); err == nil {
+		t.Fatal("SetLimit(0) succeeded")
+	}
+}
+
+func TestSetLimitBurstOverflow(t *testing.T) {
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 10, Burst: 5})
+	if err := fw.SetLimit(math.MaxUint64); err == nil {
+		t.Fatal("SetLimit(MaxUint64) with Burst 5 succeeded")
+	}
+	if got := fw.EffectiveLimit(); got != 15 {
+		t.Fatalf("effective limit %d after the rejected SetLimit, want 15", got)
+	}
+	if err := fw.SetLimit(math.MaxUint64 - 5); err != nil {
+		t.Fatal(err)
+	}
+	if got := fw.EffectiveLimit(); got != math.MaxUint64 {
+		t.Fatalf("effective limit %d, want %d", got, uint64(math.MaxUint64))
+	}
+}
+
+func TestSetWindow(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Hour, Limit: 1, Clock: clock})
+	fw.Accept()
+	clock.Add(20 * time.Second)
+	if err := fw.SetWindow(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if fw.Accept() {
+		t.Fatal("the count did not carry over into the new window")
+	}
+	for i := range 3 {
+		if got := fw.ResetIn(); got != 10*time.Second {
+			t.Fatalf("window %d resets in %v, want 10s", i, got)
+		}
+		clock.Add(10 * time.Second)
+		if !fw.Accept() {
+			t.Fatalf("window %d did not reset after 10s", i+1)
+		}
+		if fw.Accept() {
+			t.Fatalf("window %d accepted over the limit", i+1)
+		}
+	}
+	for _, d := range []time.Duration{0, -time.Second} {
+		if err := fw.SetWindow(d); err == nil {
+			t.Fatalf("SetWindow(%v) succeeded", d)
+		}
+	}
+}
+
+func TestOnReject(t *testing.T) {
+	var rejected int
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 3))
+	fw.OnReject = func() {
+		rejected++
+		// The hook runs outside the lock, so it may use the limiter.
+		_ = fw.Remaining()
+	}
+	accepted := 0
+	for range 10 {
+		if fw.Accept() {
+			accepted++
+		}
+	}
+	if accepted != 3 || rejected != 7 {
+		t.Fatalf("%d accepted and OnReject called %d times, want 3 and 7", accepted, rejected)
+	}
+}
+
+// waitGoroutines fails t unless the number of goroutines drops back to at
+// most want within a second.
+func waitGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running, want %d", runtime.NumGoroutine(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStopLeavesNoGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	fw := ratelimit.NewFixedWindow(10*time.Millisecond, 1)
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	fw.Accept()
+	done := make(chan error)
+	go func() { done <- fw.Wait(context.Background()) }()
+	fw.Stop()
+	<-done
+	waitGoroutines(t, before)
+}
+
+func TestStopTwice(t *testing.T) {
+	fw := ratelimit.NewFixedWindow(time.Hour, 5)
+	fw.Clock = newFakeClock()
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	fw.AcceptN(3)
+	for range 3 {
+		fw.Stop()
+	}
+	if fw.IsRunning() {
+		t.Fatal("still running after Stop")
+	}
+	if fw.Accept() {
+		t.Fatal("accepted after Stop")
+	}
+	if got := fw.FinalCount(); got != 3 {
+		t.Fatalf("final count %d after repeated Stop, want 3", got)
+	}
+	if err := fw.Wait(context.Background()); err != ratelimit.ErrStopped {
+		t.Fatalf("Wait after Stop returned %v, want ErrStopped", err)
+	}
+}
+
+func TestAcceptCtx(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ok, err := fw.AcceptCtx(ctx); ok || !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled context: got %v, %v, want false, %v", ok, err, context.Canceled)
+	}
+	if got := fw.Remaining(); got != 1 {
+		t.Fatalf("cancelled context used a slot: remaining %d, want 1", got)
+	}
+	if ok, err := fw.AcceptCtx(context.Background()); !ok || err != nil {
+		t.Fatalf("live context: got %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := fw.AcceptCtx(context.Background()); ok || err != nil {
+		t.Fatalf("live context on a full window: got %v, %v, want false, nil", ok, err)
+	}
+	fw.Stop()
+	if _, err := fw.AcceptCtx(context.Background()); !errors.Is(err, ratelimit.ErrStopped) {
+		t.Fatalf("after Stop: got %v, want %v", err, ratelimit.ErrStopped)
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 4))
+	for _, want := range []float64{0, 0.5, 1} {
+		if got := fw.Utilization(); got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		fw.AcceptN(2)
+	}
+
+	// Burst is part of the limit, so using only Limit is not full.
+	burst := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Hour, Limit: 4, Burst: 4})
+	burst.AcceptN(4)
+	if got := burst.Utilization(); got != 0.5 {
+		t.Fatalf("with Burst: got %v, want 0.5", got)
+	}
+	burst.AcceptN(4)
+	if got := burst.Utilization(); got != 1 {
+		t.Fatalf("with Burst used up: got %v, want 1", got)
+	}
+}
+
+func TestMinInterval(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 10, MinInterval: 5 * time.Second, Clock: clock})
+	for i, step := range []struct {
+		advance time.Duration
+		want    bool
+	}{
+		{0, true},
+		// Window capacity remains, but the gap is too short.
+		{time.Second, false},
+		{3 * time.Second, false},
+		{time.Second, true},
+		// Rejected requests do not push the next slot back.
+		{4 * time.Second, false},
+		{time.Second, true},
+	} {
+		clock.Add(step.advance)
+		if got := fw.Accept(); got != step.want {
+			t.Fatalf("step %d: got %v, want %v", i, got, step.want)
+		}
+	}
+	if got := fw.RetryAfter(); got != 5*time.Second {
+		t.Fatalf("retry after %v, want 5s", got)
+	}
+}
+
+func TestStopReleasesWaiters(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	fw.Accept()
+	const waiters = 5
+	errs := make(chan error, waiters)
+	for range waiters {
+		go func() { errs <- fw.Wait(context.Background()) }()
+	}
+	// Give the waiters time to block on the full window.
+	time.Sleep(20 * time.Millisecond)
+	fw.Stop()
+	timeout := time.After(time.Second)
+	for i := range waiters {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ratelimit.ErrStopped) {
+				t.Fatalf("waiter %d: got %v, want %v", i, err, ratelimit.ErrStopped)
+			}
+		case <-timeout:
+			t.Fatalf("%d of %d waiters still blocked after Stop", waiters-i, waiters)
+		}
+	}
+}
+
+func TestValidateDurationOverflow(t *testing.T) {
+	for _, tc := range []struct {
+		duration uint64
+		unit     string
+		ok       bool
+	}{
+		{106751, "day", true},
+		{106752, "day", false},
+		{1 << 40, "hour", false},
+		{math.MaxUint64, "millisecond", false},
+	} {
+		fw := &ratelimit.FixedWindow{Duration: tc.duration, Unit: tc.unit, Limit: 1}
+		err := fw.Validate()
+		if tc.ok {
+			if err != nil {
+				t.Fatalf("%d %s: %v", tc.duration, tc.unit, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "longer than a time.Duration") {
+			t.Fatalf("%d %s: got %v, want an overflow error", tc.duration, tc.unit, err)
+		}
+		if err := fw.Do(); err == nil {
+			fw.Stop()
+			t.Fatalf("%d %s: Do started a limiter with an overflowing window", tc.duration, tc.unit)
+		}
+	}
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1 << 40, Unit: "millisecond", Limit: 1})
+	if err := fw.SetUnit("day"); err == nil {
+		t.Fatal("SetUnit accepted a unit that overflows the window")
+	}
+}
+
+func TestBurst(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 3, Burst: 2, Clock: clock})
+	if got := fw.WindowLimit(); got != 5 {
+		t.Fatalf("window limit %d, want 5", got)
+	}
+	for i := range 5 {
+		if !fw.Accept() {
+			t.Fatalf("request %d rejected within Limit plus Burst", i)
+		}
+	}
+	if fw.Accept() {
+		t.Fatal("accepted beyond Limit plus Burst")
+	}
+	// The burst allowance comes back with the next window.
+	clock.Add(time.Minute)
+	if got := fw.Remaining(); got != 5 {
+		t.Fatalf("remaining %d in the next window, want 5", got)
+	}
+}
+
+func TestFinalCount(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 10))
+	fw.AcceptN(7)
+	want := fw.Counter()
+	fw.Stop()
+	if got := fw.FinalCount(); got != want {
+		t.Fatalf("final count %d, want %d", got, want)
+	}
+	// Later calls neither change it nor count.
+	fw.Accept()
+	fw.Stop()
+	if got := fw.FinalCount(); got != want {
+		t.Fatalf("final count %d after a second Stop, want %d", got, want)
+	}
+	if got := (&ratelimit.FixedWindow{}).FinalCount(); got != 0 {
+		t.Fatalf("final count %d before Do, want 0", got)
+	}
+}
+
+func TestWaitN(t *testing.T) {
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: 50 * time.Millisecond, Limit: 3, Burst: 1})
+	if err := fw.WaitN(context.Background(), 5); !errors.Is(err, ratelimit.ErrExceedsLimit) {
+		t.Fatalf("WaitN(5) with room for 4 returned %v, want %v", err, ratelimit.ErrExceedsLimit)
+	}
+	if err := fw.WaitN(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	// Three more do not fit until the window resets.
+	reset := fw.NextResetTime()
+	if err := fw.WaitN(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	if now := time.Now(); now.Before(reset) {
+		t.Fatalf("WaitN returned %v before the window reset", reset.Sub(now))
+	}
+	if got := fw.Counter(); got != 3 {
+		t.Fatalf("counter %d after WaitN, want 3", got)
+	}
+
+	full := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 3))
+	full.AcceptN(2)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := full.WaitN(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitN returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := full.Counter(); got != 2 {
+		t.Fatalf("counter %d after a cancelled WaitN, want 2", got)
+	}
+}
+
+func TestClone(t *testing.T) {
+	template := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 3})
+	template.SetLimit(2)
+	template.AcceptN(2)
+
+	clone := template.Clone()
+	if clone.Duration != 1 || clone.Unit != "minute" || clone.Limit != 2 {
+		t.Fatalf("clone has duration %d, unit %q, limit %d, want 1, minute, 2", clone.Duration, clone.Unit, clone.Limit)
+	}
+	if clone.IsRunning() {
+		t.Fatal("clone of a started limiter is already running")
+	}
+	startFixedWindow(t, clone)
+	if got := clone.Counter(); got != 0 {
+		t.Fatalf("clone starts with counter %d, want 0", got)
+	}
+	for i := range 2 {
+		if !clone.Accept() {
+			t.Fatalf("clone rejected request %d despite the template being full", i)
+		}
+	}
+	if got := template.Counter(); got != 2 {
+		t.Fatalf("template counter %d after accepting on the clone, want 2", got)
+	}
+
+	// Stopping either leaves the other usable.
+	template.Stop()
+	clone.Reset()
+	if !clone.Accept() {
+		t.Fatal("clone rejected a request after the template was stopped")
+	}
+}
+
+func TestCloneLeavesStoreUnset(t *testing.T) {
+	template := &ratelimit.FixedWindow{Window: time.Minute, Limit: 1, Store: &ratelimit.MemoryStore{}, Key: "shared", FailOpen: true}
+	clone := template.Clone()
+	if clone.Store != nil || clone.Key != "" {
+		t.Fatalf("clone has store %v, key %q, want neither", clone.Store, clone.Key)
+	}
+	if !clone.FailOpen {
+		t.Fatal("clone lost FailOpen")
+	}
+}
+
+func TestAcceptUpTo(t *testing.T) {
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Hour, Limit: 5})
+	if got := fw.AcceptUpTo(3); got != 3 {
+		t.Fatalf("AcceptUpTo(3) in an empty window = %d, want 3", got)
+	}
+	// Only two of four fit.
+	if got := fw.AcceptUpTo(4); got != 2 {
+		t.Fatalf("AcceptUpTo(4) with 2 left = %d, want 2", got)
+	}
+	if got := fw.Counter(); got != 5 {
+		t.Fatalf("counter %d after a partial fill, want 5", got)
+	}
+	if got := fw.AcceptUpTo(1); got != 0 {
+		t.Fatalf("AcceptUpTo(1) in a full window = %d, want 0", got)
+	}
+	if got := fw.Counter(); got != 5 {
+		t.Fatalf("counter %d after AcceptUpTo on a full window, want 5", got)
+	}
+}
+
+func TestOnFullOncePerWindow(t *testing.T) {
+	clock := newFakeClock()
+	var full atomic.Int32
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{
+		Window: time.Minute, Limit: 2, Burst: 1, Clock: clock,
+		OnFull: func() { full.Add(1) },
+	})
+	fw.AcceptN(2)
+	if got := full.Load(); got != 0 {
+		t.Fatalf("OnFull ran %d times with Burst left, want 0", got)
+	}
+	fw.Accept()
+	for range 3 {
+		fw.Accept()
+	}
+	fw.AcceptUpTo(2)
+	if got := full.Load(); got != 1 {
+		t.Fatalf("OnFull ran %d times in the first window, want 1", got)
+	}
+
+	// Concurrent requests filling the next window still run it once.
+	clock.Add(time.Minute)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fw.Accept()
+		}()
+	}
+	wg.Wait()
+	if got := full.Load(); got != 2 {
+		t.Fatalf("OnFull ran %d times over two full windows, want 2", got)
+	}
+
+	// A window that is never filled does not run it.
+	clock.Add(time.Minute)
+	fw.AcceptN(2)
+	if got := full.Load(); got != 2 {
+		t.Fatalf("OnFull ran %d times after a window with room, want 2", got)
+	}
+}
+
+func TestIsRunning(t *testing.T) {
+	fw := ratelimit.NewFixedWindow(time.Minute, 1)
+	if fw.IsRunning() {
+		t.Fatal("running before Do")
+	}
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	if !fw.IsRunning() {
+		t.Fatal("not running after Do")
+	}
+	fw.Stop()
+	if fw.IsRunning() {
+		t.Fatal("running after Stop")
+	}
+
+	failed := &ratelimit.FixedWindow{Window: time.Minute}
+	if err := failed.Do(); err == nil {
+		t.Fatal("Do accepted a zero limit")
+	}
+	if failed.IsRunning() {
+		t.Fatal("running after a failed Do")
+	}
+}
+
+func TestFlushRealignsWindow(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 3, Clock: clock})
+	fw.AcceptN(3)
+	clock.Add(20 * time.Second)
+
+	// Reset empties the window but keeps its schedule.
+	fw.Reset()
+	if got, want := fw.NextResetTime(), start.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("next reset after Reset %v, want %v", got, want)
+	}
+
+	fw.AcceptN(3)
+	fw.Flush()
+	if got := fw.Counter(); got != 0 {
+		t.Fatalf("counter %d after Flush, want 0", got)
+	}
+	flushedAt := clock.Now()
+	if got, want := fw.NextResetTime(), flushedAt.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("next reset after Flush %v, want %v", got, want)
+	}
+	if !fw.AcceptN(3) || fw.Accept() {
+		t.Fatal("the flushed window does not hold exactly Limit requests")
+	}
+	// The old boundary passes without a reset.
+	clock.Add(40 * time.Second)
+	if fw.Accept() {
+		t.Fatal("accepted at the boundary Flush moved")
+	}
+	clock.Add(20 * time.Second)
+	if !fw.Accept() {
+		t.Fatal("rejected a full window after Flush")
+	}
+}
+
+func TestSetUnit(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 2, Unit: "minute", Limit: 2, Clock: clock})
+	fw.Accept()
+	if err := fw.SetUnit("second"); err != nil {
+		t.Fatal(err)
+	}
+	if fw.Unit != "second" {
+		t.Fatalf("unit %q after SetUnit, want second", fw.Unit)
+	}
+	// The count is kept and a two-second window starts now.
+	if got := fw.Counter(); got != 1 {
+		t.Fatalf("counter %d after SetUnit, want 1", got)
+	}
+	if got, want := fw.NextResetTime(), clock.Now().Add(2*time.Second); !got.Equal(want) {
+		t.Fatalf("next reset %v, want %v", got, want)
+	}
+	fw.Accept()
+	clock.Add(2 * time.Second)
+	if !fw.Accept() {
+		t.Fatal("rejected after the new, shorter window reset")
+	}
+}
+
+func TestSetUnitInvalid(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 1, Clock: clock})
+	fw.Accept()
+	reset := fw.NextResetTime()
+	for _, unit := range []string{"fortnight", ""} {
+		if err := fw.SetUnit(unit); err == nil {
+			t.Fatalf("SetUnit(%q) returned nil", unit)
+		}
+	}
+	if fw.Unit != "minute" {
+		t.Fatalf("unit %q after failed SetUnit, want minute", fw.Unit)
+	}
+	if got := fw.NextResetTime(); !got.Equal(reset) {
+		t.Fatalf("next reset %v after failed SetUnit, want %v", got, reset)
+	}
+	clock.Add(59 * time.Second)
+	if fw.Accept() {
+		t.Fatal("accepted before the unchanged minute window reset")
+	}
+	clock.Add(time.Second)
+	if !fw.Accept() {
+		t.Fatal("rejected after the minute window reset")
+	}
+}
+
+func TestRatePerSecond(t *testing.T) {
+	for _, tc := range []struct {
+		fw   *ratelimit.FixedWindow
+		want float64
+	}{
+		{&ratelimit.FixedWindow{Duration: 1, Unit: "second", Limit: 5}, 5},
+		{&ratelimit.FixedWindow{Duration: 2, Unit: "second", Limit: 5}, 2.5},
+		{&ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 60}, 1},
+		{&ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 30}, 0.5},
+		{&ratelimit.FixedWindow{Duration: 1, Unit: "hour", Limit: 3600}, 1},
+		{&ratelimit.FixedWindow{Duration: 2, Unit: "hour", Limit: 720}, 0.1},
+		{&ratelimit.FixedWindow{Window: 500 * time.Millisecond, Limit: 5}, 10},
+		{&ratelimit.FixedWindow{Duration: 1, Unit: "fortnight", Limit: 5}, 0},
+	} {
+		if got := tc.fw.RatePerSecond(); math.Abs(got-tc.want) > 1e-9 {
+			t.Fatalf("%d per %d %s (window %v): got %v, want %v", tc.fw.Limit, tc.fw.Duration, tc.fw.Unit, tc.fw.Window, got, tc.want)
+		}
+	}
+
+	// Started limiters, and SetLimit, are reflected too.
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 120})
+	if got := fw.RatePerSecond(); got != 2 {
+		t.Fatalf("started: got %v, want 2", got)
+	}
+	fw.SetLimit(60)
+	if got := fw.RatePerSecond(); got != 1 {
+		t.Fatalf("after SetLimit(60): got %v, want 1", got)
+	}
+}
+
+// TestBeforeDo calls every read and update method on a limiter that was
+// never started, none of which may panic.
+func TestBeforeDo(t *testing.T) {
+	fw := ratelimit.NewFixedWindow(time.Minute, 3)
+	if fw.Accept() || fw.AcceptN(1) || fw.AcceptUpTo(2) != 0 {
+		t.Fatal("accepted before Do")
+	}
+	if ok, err := fw.TryAccept(); ok || err == nil {
+		t.Fatalf("TryAccept before Do: got %v, %v, want false and an error", ok, err)
+	}
+	if err := fw.WaitN(context.Background(), 1); err == nil {
+		t.Fatal("WaitN before Do returned nil")
+	}
+	fw.Refund(1)
+	if got := fw.Counter(); got != 0 {
+		t.Fatalf("counter %d before Do, want 0", got)
+	}
+	if got := fw.Remaining(); got != 3 {
+		t.Fatalf("remaining %d before Do, want 3", got)
+	}
+	if got := fw.WindowLimit(); got != 3 {
+		t.Fatalf("window limit %d before Do, want 3", got)
+	}
+	if got := fw.EffectiveLimit(); got != 3 {
+		t.Fatalf("effective limit %d before Do, want 3", got)
+	}
+	if got := fw.Utilization(); got != 0 {
+		t.Fatalf("utilization %v before Do, want 0", got)
+	}
+	if got := fw.RetryAfter(); got != 0 {
+		t.Fatalf("retry after %v before Do, want 0", got)
+	}
+	if got := fw.ResetIn(); got != 0 {
+		t.Fatalf("reset in %v before Do, want 0", got)
+	}
+	if s := fw.Snapshot(); s.Limit != 3 || s.Remaining != 3 {
+		t.Fatalf("snapshot before Do has limit %d, remaining %d, want 3, 3", s.Limit, s.Remaining)
+	}
+	fw.Reset()
+	fw.Flush()
+	if r := fw.Reserve(); r.OK() {
+		t.Fatal("reserved a slot before Do")
+	}
+
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+	if !fw.Accept() {
+		t.Fatal("rejected after Do")
+	}
+}
@@ -0,0 +1,59 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestAcceptWithReason(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	for i, want := range []struct {
+		ok     bool
+		reason ratelimit.Reason
+	}{
+		{true, ratelimit.ReasonOK},
+		{false, ratelimit.ReasonLimitReached},
+	} {
+		if ok, reason := fw.AcceptWithReason(); ok != want.ok || reason != want.reason {
+			t.Fatalf("request %d: got %v, %v, want %v, %v", i, ok, reason, want.ok, want.reason)
+		}
+	}
+	fw.Stop()
+	if ok, reason := fw.AcceptWithReason(); ok || reason != ratelimit.ReasonStopped {
+		t.Fatalf("after Stop: got %v, %v, want false, %v", ok, reason, ratelimit.ReasonStopped)
+	}
+}
+
+func TestAcceptWithReasonFunc(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	fw.Stop()
+	for _, tc := range []struct {
+		name   string
+		rl     ratelimit.Limiter
+		ok     bool
+		reason ratelimit.Reason
+	}{
+		{"NoOp", ratelimit.NoOp{}, true, ratelimit.ReasonOK},
+		{"Accept only", retryLimiter{}, false, ratelimit.ReasonLimitReached},
+		{"ReasonAccepter", fw, false, ratelimit.ReasonStopped},
+	} {
+		if ok, reason := ratelimit.AcceptWithReason(tc.rl); ok != tc.ok || reason != tc.reason {
+			t.Fatalf("%s: got %v, %v, want %v, %v", tc.name, ok, reason, tc.ok, tc.reason)
+		}
+	}
+}
+
+func TestReasonString(t *testing.T) {
+	for reason, want := range map[ratelimit.Reason]string{
+		ratelimit.ReasonOK:           "ok",
+		ratelimit.ReasonLimitReached: "limit reached",
+		ratelimit.ReasonStopped:      "stopped",
+		ratelimit.Reason(99):         "unknown",
+	} {
+		if got := reason.String(); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Package redislimit provides rate limiters whose state lives in Redis, so
+// that several processes can share one limit. It is its own module, so the
+// Redis client is only a dependency of programs that use it.
+package redislimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/govi230/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+// incr adds ARGV[2] to the counter and sets its expiry of ARGV[1]
+// milliseconds when the key is first created, in one round trip.
+var incr = redis.NewScript(`
+local n = redis.call("INCRBY", KEYS[1], ARGV[2])
+if n == tonumber(ARGV[2]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// RedisFixedWindow allows at most Limit requests per Window across every
+// process sharing the same Client and Namespace.
+//
+// Each window is a Redis key named after Namespace and the window number,
+// counted by a Store and left to expire once the window is over. Unlike a
+// ratelimit.FixedWindow with a Store, whose windows start when Do is
+// called, windows are aligned to multiples of Window since the Unix epoch,
+// so every process shares the same ones. If Redis cannot be reached,
+// Accept returns FailOpen.
+//
+// The window number comes from the local clock, so processes whose clocks
+// disagree count into different windows around each boundary. Set
+// ServerTime to take it from the Redis TIME command instead, which puts
+// every process on the same window at the cost of a second round trip.
+type RedisFixedWindow struct {
+	Client    redis.UniversalClient
+	Namespace string
+	Window    time.Duration
+	Limit     uint64
+	FailOpen  bool
+
+	// ServerTime makes Accept read the time from Redis, falling back to
+	// the local clock if TIME fails.
+	ServerTime bool
+	// Clock, if set, replaces the local clock.
+	Clock ratelimit.Clock
+
+	mu   sync.RWMutex
+	stop bool
+}
+
+// Validate checks that the limiter has a client, a namespace and a usable
+// window and limit.
+func (rw *RedisFixedWindow) Validate() error {
+	if rw.Client == nil {
+		return errors.New("redis client must be set")
+	}
+	if rw.Namespace == "" {
+		return errors.New("namespace must be set")
+	}
+	if rw.Window < time.Millisecond {
+		return errors.New("window must be at least one millisecond")
+	}
+	if rw.Limit == 0 {
+		return errors.New("limit must be greater than zero")
+	}
+	return nil
+}
+
+// Do validates the configuration. There is nothing to start, since windows
+// are expired by Redis.
+func (rw *RedisFixedWindow) Do() error {
+	return rw.Validate()
+}
+
+// Accept counts the request in the shared window and reports whether the
+// window is still within Limit.
+func (rw *RedisFixedWindow) Accept() bool {
+	rw.mu.RLock()
+	stopped := rw.stop
+	rw.mu.RUnlock()
+	if stopped {
+		return false
+	}
+	ctx := context.Background()
+	n, err := rw.incr(ctx, rw.now(ctx))
+	if err != nil {
+		return rw.FailOpen
+	}
+	return n <= rw.Limit
+}
+
+// Stop makes Accept reject every request. The shared counters are left in
+// Redis for the other processes.
+func (rw *RedisFixedWindow) Stop() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.stop = true
+}
+
+// now returns the time that decides the window: that of the Redis server
+// with ServerTime, or the local one.
+func (rw *RedisFixedWindow) now(ctx context.Context) time.Time {
+	if rw.ServerTime {
+		if t, err := rw.Client.Time(ctx).Result(); err == nil {
+			return t
+		}
+	}
+	if rw.Clock != nil {
+		return rw.Clock.Now()
+	}
+	return time.Now()
+}
+
+func (rw *RedisFixedWindow) incr(ctx context.Context, now time.Time) (uint64, error) {
+	window := now.UnixNano() / int64(rw.Window)
+	end := time.Unix(0, (window+1)*int64(rw.Window))
+	store := Store{Client: rw.Client, Prefix: rw.Namespace + ":"}
+	return store.incr(ctx, strconv.FormatInt(window, 10), 1, end.Sub(now))
+}
+
+// Store is a ratelimit.Store backed by Redis. Setting it as the Store of a
+// ratelimit.FixedWindow shares that limiter's count between processes.
+type Store struct {
+	Client redis.UniversalClient
+	// Prefix is prepended to every key.
+	Prefix string
+}
+
+// Incr implements ratelimit.Store. Redis expires keys to the millisecond,
+// so ttl is rounded up to a whole one.
+func (s *Store) Incr(key string, n uint64, ttl time.Duration) (uint64, error) {
+	return s.incr(context.Background(), key, n, ttl)
+}
+
+func (s *Store) incr(ctx context.Context, key string, n uint64, ttl time.Duration) (uint64, error) {
+	ms := max((ttl+time.Millisecond-1)/time.Millisecond, 1)
+	return incr.Run(ctx, s.Client, []string{s.Prefix + key}, int64(ms), n).Uint64()
+}
+
+// Reset implements ratelimit.Store.
+func (s *Store) Reset(key string) {
+	s.Client.Del(context.Background(), s.Prefix+key)
+}
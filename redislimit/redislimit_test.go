@@ -0,0 +1,170 @@
+package redislimit_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/govi230/ratelimit"
+	"github.com/govi230/ratelimit/redislimit"
+	"github.com/redis/go-redis/v9"
+)
+
+// newClient returns a miniredis server and a client for it that does not
+// retry, so that tests of an unavailable server fail fast.
+func newClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestRedisFixedWindowShared(t *testing.T) {
+	_, client := newClient(t)
+	a := &redislimit.RedisFixedWindow{Client: client, Namespace: "api", Window: time.Minute, Limit: 3}
+	b := &redislimit.RedisFixedWindow{Client: client, Namespace: "api", Window: time.Minute, Limit: 3}
+	other := &redislimit.RedisFixedWindow{Client: client, Namespace: "other", Window: time.Minute, Limit: 1}
+	for _, rw := range []*redislimit.RedisFixedWindow{a, b, other} {
+		if err := rw.Do(); err != nil {
+			t.Fatal(err)
+		}
+		defer rw.Stop()
+	}
+	if !a.Accept() || !b.Accept() || !a.Accept() {
+		t.Fatal("rejected within the shared limit")
+	}
+	if b.Accept() || a.Accept() {
+		t.Fatal("accepted over the shared limit")
+	}
+	if !other.Accept() {
+		t.Fatal("another namespace shares the count")
+	}
+	a.Stop()
+	if a.Accept() {
+		t.Fatal("accepted after Stop")
+	}
+}
+
+func TestRedisFixedWindowExpiry(t *testing.T) {
+	mr, client := newClient(t)
+	now := time.Date(2024, 1, 1, 0, 0, 50, 0, time.UTC)
+	rw := &redislimit.RedisFixedWindow{Client: client, Namespace: "api", Window: time.Minute, Limit: 1, Clock: fixedClock(now)}
+	if err := rw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	rw.Accept()
+	key := "api:" + strconv.FormatInt(now.UnixNano()/int64(time.Minute), 10)
+	if !mr.Exists(key) {
+		t.Fatalf("no key %s for the window, have %v", key, mr.Keys())
+	}
+	if ttl := mr.TTL(key); ttl != 10*time.Second {
+		t.Fatalf("key expires in %v, want 10s, when the window ends", ttl)
+	}
+}
+
+func TestRedisFixedWindowUnavailable(t *testing.T) {
+	for _, failOpen := range []bool{false, true} {
+		mr, client := newClient(t)
+		rw := &redislimit.RedisFixedWindow{Client: client, Namespace: "api", Window: time.Minute, Limit: 1, FailOpen: failOpen}
+		if err := rw.Do(); err != nil {
+			t.Fatal(err)
+		}
+		rw.Accept()
+		mr.Close()
+		for range 3 {
+			if got := rw.Accept(); got != failOpen {
+				t.Fatalf("FailOpen %v: Accept with Redis down returned %v", failOpen, got)
+			}
+		}
+	}
+}
+
+func TestRedisFixedWindowValidate(t *testing.T) {
+	_, client := newClient(t)
+	for _, rw := range []*redislimit.RedisFixedWindow{
+		{Namespace: "api", Window: time.Minute, Limit: 1},
+		{Client: client, Window: time.Minute, Limit: 1},
+		{Client: client, Namespace: "api", Window: time.Microsecond, Limit: 1},
+		{Client: client, Namespace: "api", Window: time.Minute},
+	} {
+		if err := rw.Validate(); err == nil {
+			t.Errorf("Validate accepted %+v", rw)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	mr, client := newClient(t)
+	store := &redislimit.Store{Client: client, Prefix: "rl:"}
+	var _ ratelimit.Store = store
+	fw := &ratelimit.FixedWindow{Window: time.Minute, Limit: 3, Store: store, Key: "k"}
+	if err := fw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Stop()
+	if !fw.AcceptN(2) || !fw.Accept() || fw.Accept() {
+		t.Fatal("Store-backed limiter did not enforce its limit")
+	}
+	if !mr.Exists("rl:k") {
+		t.Fatal("count not kept under the prefixed key")
+	}
+	if ttl := mr.TTL("rl:k"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("key expires in %v, want by the end of the window", ttl)
+	}
+	fw.Reset()
+	if mr.Exists("rl:k") || !fw.Accept() {
+		t.Fatal("Reset did not clear the count in Redis")
+	}
+
+	mr.Close()
+	if fw.Accept() {
+		t.Fatal("accepted with Redis down and FailOpen unset")
+	}
+	fw.FailOpen = true
+	if !fw.Accept() {
+		t.Fatal("rejected with Redis down and FailOpen set")
+	}
+}
+
+// fixedClock always reports the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func TestRedisFixedWindowServerTime(t *testing.T) {
+	boundary := time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC)
+	for _, serverTime := range []bool{false, true} {
+		mr, client := newClient(t)
+		mr.SetTime(boundary.Add(-30 * time.Second))
+		// The local clocks disagree about which side of the boundary it is.
+		var limiters []*redislimit.RedisFixedWindow
+		for _, skew := range []time.Duration{-time.Second, time.Second} {
+			rw := &redislimit.RedisFixedWindow{
+				Client:     client,
+				Namespace:  "api",
+				Window:     time.Minute,
+				Limit:      1,
+				ServerTime: serverTime,
+				Clock:      fixedClock(boundary.Add(skew)),
+			}
+			if err := rw.Do(); err != nil {
+				t.Fatal(err)
+			}
+			defer rw.Stop()
+			limiters = append(limiters, rw)
+		}
+		if !limiters[0].Accept() {
+			t.Fatalf("ServerTime %v: first request rejected", serverTime)
+		}
+		// On local time each limiter counts into its own window; on the
+		// server's they share one, which the first request filled.
+		if got, want := limiters[1].Accept(), !serverTime; got != want {
+			t.Fatalf("ServerTime %v: second limiter's request accepted %v, want %v", serverTime, got, want)
+		}
+		if got, want := len(mr.Keys()), map[bool]int{false: 2, true: 1}[serverTime]; got != want {
+			t.Fatalf("ServerTime %v: %d window keys, want %d", serverTime, got, want)
+		}
+	}
+}
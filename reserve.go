@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Reservation is a slot held in a FixedWindow, either in the current window
+// or a later one. The caller can wait for Delay and then act, or give the
+// slot back with Cancel.
+type Reservation struct {
+	fw     *FixedWindow
+	ok     bool
+	window int64 // windowStart.UnixNano() of the reserved window
+	at     time.Time
+	once   sync.Once
+}
+
+// Reserve holds a slot in the first window that has room, starting with the
+// current one. MinInterval is not applied to reservations.
+//
+// The reservation is not OK if the limiter is stopped, not started, or
+// backed by a Store. With ResetOnFirstRequest later windows have no fixed
+// start, so only the current window can be reserved.
+func (fw *FixedWindow) Reserve() *Reservation {
+	r := &Reservation{fw: fw}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if !fw.started || fw.stop || fw.Store != nil {
+		return r
+	}
+	now := fw.now()
+	fw.advanceLocked(now)
+	if _, ok := fw.add(1, fw.limitAt(now)); ok {
+		r.ok, r.window, r.at = true, fw.windowStart.UnixNano(), now
+		return r
+	}
+	if fw.ResetOnFirstRequest {
+		return r
+	}
+	for start := fw.windowStart.Add(fw.period); ; start = start.Add(fw.period) {
+		key := start.UnixNano()
+		if fw.reserved[key] < fw.limitAt(start) {
+			if fw.reserved == nil {
+				fw.reserved = make(map[int64]uint64)
+			}
+			fw.reserved[key]++
+			r.ok, r.window, r.at = true, key, start
+			return r
+		}
+	}
+}
+
+// OK reports whether a slot was reserved.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns how long until the reserved slot can be used, or zero if it
+// can be used now.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return 0
+	}
+	r.fw.mu.RLock()
+	defer r.fw.mu.RUnlock()
+	return max(r.at.Sub(r.fw.now()), 0)
+}
+
+// Cancel gives the slot back, if its window has not ended yet. Calling it
+// more than once has no further effect.
+func (r *Reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+	r.once.Do(func() {
+		fw := r.fw
+		fw.mu.Lock()
+		defer fw.mu.Unlock()
+		fw.advanceLocked(fw.now())
+		switch current := fw.windowStart.UnixNano(); {
+		case r.window == current:
+			if fw.counter.Load() > 0 {
+				fw.highWater = fw.peakLocked()
+				fw.sub(1)
+				fw.wake()
+			}
+		case r.window > current:
+			if fw.reserved[r.window] > 0 {
+				fw.reserved[r.window]--
+			}
+		}
+	})
+}
+
+// takeReservedLocked removes and returns the reservations for the window
+// starting at start, dropping any for earlier windows. fw.mu must be held.
+func (fw *FixedWindow) takeReservedLocked(start time.Time) uint64 {
+	if len(fw.reserved) == 0 {
+		return 0
+	}
+	key := start.UnixNano()
+	n := fw.reserved[key]
+	for k := range fw.reserved {
+		if k <= key {
+			delete(fw.reserved, k)
+		}
+	}
+	return n
+}
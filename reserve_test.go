@@ -0,0 +1,61 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestReserveDelay(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 2, Clock: clock})
+	clock.Add(10 * time.Second)
+	for i, want := range []time.Duration{0, 0, 50 * time.Second, 50 * time.Second, 110 * time.Second} {
+		r := fw.Reserve()
+		if !r.OK() {
+			t.Fatalf("reservation %d not OK", i)
+		}
+		if got := r.Delay(); got != want {
+			t.Fatalf("reservation %d: delay %v, want %v", i, got, want)
+		}
+	}
+	clock.Add(50 * time.Second)
+	// The next window starts with its two reservations.
+	if fw.Accept() {
+		t.Fatal("accepted in a window that was reserved in full")
+	}
+}
+
+func TestReserveCancel(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 1, Clock: clock})
+	now := fw.Reserve()
+	later := fw.Reserve()
+	now.Cancel()
+	now.Cancel()
+	if got := fw.Remaining(); got != 1 {
+		t.Fatalf("remaining %d after Cancel, want 1", got)
+	}
+	later.Cancel()
+	clock.Add(time.Minute)
+	if got := fw.Remaining(); got != 1 {
+		t.Fatalf("next window remaining %d after Cancel, want 1", got)
+	}
+	if !fw.Accept() {
+		t.Fatal("rejected in a window whose reservation was cancelled")
+	}
+}
+
+func TestReserveNotOK(t *testing.T) {
+	fw := ratelimit.NewFixedWindow(time.Minute, 1)
+	if fw.Reserve().OK() {
+		t.Fatal("reserved before Do")
+	}
+	startFixedWindow(t, fw).Stop()
+	r := fw.Reserve()
+	if r.OK() || r.Delay() != 0 {
+		t.Fatalf("after Stop: OK %v, delay %v", r.OK(), r.Delay())
+	}
+	r.Cancel()
+}
@@ -0,0 +1,193 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// SlidingWindowLog allows at most Limit requests in any rolling window of
+// Duration Unit.
+//
+// It remembers the time of every accepted request still inside the window,
+// so memory grows with the requests accepted, up to Limit timestamps: a
+// limiter allowing 10000 requests per hour may hold 10000 of them. In
+// exchange it never admits the 2x Limit burst a FixedWindow allows across
+// a window boundary.
+type SlidingWindowLog struct {
+	Duration uint64
+	Unit     string
+	Limit    uint64
+
+	log  []time.Time
+	mu   *sync.Mutex
+	stop bool
+}
+
+// Validate checks that the window and limit are usable.
+func (sw *SlidingWindowLog) Validate() error {
+	if sw.Duration == 0 {
+		return errors.New("duration must be greater than zero")
+	}
+	if sw.Limit == 0 {
+		return errors.New("limit must be greater than zero")
+	}
+	return validateWindow(sw.Duration, sw.Unit)
+}
+
+// Do validates the configuration and clears the log.
+func (sw *SlidingWindowLog) Do() error {
+	if err := sw.Validate(); err != nil {
+		return err
+	}
+	if sw.mu == nil {
+		sw.mu = &sync.Mutex{}
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.log = nil
+	return nil
+}
+
+// Accept drops entries that have left the window and records the request if
+// fewer than Limit remain.
+func (sw *SlidingWindowLog) Accept() bool {
+	return sw.acceptAt(time.Now())
+}
+
+func (sw *SlidingWindowLog) acceptAt(now time.Time) bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.stop {
+		return false
+	}
+	unit, _ := unitDuration(sw.Unit)
+	cutoff := now.Add(-time.Duration(sw.Duration) * unit)
+	i := 0
+	for i < len(sw.log) && !sw.log[i].After(cutoff) {
+		i++
+	}
+	sw.log = sw.log[i:]
+	if uint64(len(sw.log)) >= sw.Limit {
+		return false
+	}
+	sw.log = append(sw.log, now)
+	return true
+}
+
+// Refund forgets the n most recently accepted requests.
+func (sw *SlidingWindowLog) Refund(n uint64) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.log = sw.log[:uint64(len(sw.log))-min(n, uint64(len(sw.log)))]
+}
+
+// Stop makes Accept reject every request. It may be called before Do.
+func (sw *SlidingWindowLog) Stop() {
+	if sw.mu == nil {
+		sw.stop = true
+		return
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.stop = true
+	sw.log = nil
+}
+
+// SlidingWindowCounter approximates a rolling window of Duration Unit with
+// two counters: the previous window's and the current one's. The rolling
+// count is estimated as
+//
+//	previous * (fraction of the previous window still in range) + current
+//
+// and a request is accepted if that estimate stays within Limit. Memory use
+// is constant, at the cost of assuming the previous window's requests were
+// spread evenly.
+type SlidingWindowCounter struct {
+	Duration uint64
+	Unit     string
+	Limit    uint64
+
+	prev, curr  uint64
+	windowStart time.Time
+	mu          *sync.Mutex
+	stop        bool
+}
+
+// Validate checks that the window and limit are usable.
+func (sc *SlidingWindowCounter) Validate() error {
+	if sc.Duration == 0 {
+		return errors.New("duration must be greater than zero")
+	}
+	if sc.Limit == 0 {
+		return errors.New("limit must be greater than zero")
+	}
+	return validateWindow(sc.Duration, sc.Unit)
+}
+
+// Do validates the configuration and starts the first window.
+func (sc *SlidingWindowCounter) Do() error {
+	if err := sc.Validate(); err != nil {
+		return err
+	}
+	if sc.mu == nil {
+		sc.mu = &sync.Mutex{}
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.prev, sc.curr = 0, 0
+	sc.windowStart = time.Now()
+	return nil
+}
+
+// Accept reports whether the request fits in the estimated rolling window
+// and, if so, counts it.
+func (sc *SlidingWindowCounter) Accept() bool {
+	return sc.acceptAt(time.Now())
+}
+
+// Refund takes n requests back out of the current window.
+func (sc *SlidingWindowCounter) Refund(n uint64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.curr -= min(n, sc.curr)
+}
+
+// Stop makes Accept reject every request. It may be called before Do.
+func (sc *SlidingWindowCounter) Stop() {
+	if sc.mu == nil {
+		sc.stop = true
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.stop = true
+}
+
+func (sc *SlidingWindowCounter) acceptAt(now time.Time) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.stop {
+		return false
+	}
+	unit, _ := unitDuration(sc.Unit)
+	window := time.Duration(sc.Duration) * unit
+	if elapsed := now.Sub(sc.windowStart); elapsed >= window {
+		n := elapsed / window
+		if n == 1 {
+			sc.prev = sc.curr
+		} else {
+			// More than one full window has passed without a request.
+			sc.prev = 0
+		}
+		sc.curr = 0
+		sc.windowStart = sc.windowStart.Add(n * window)
+	}
+	remaining := 1 - float64(now.Sub(sc.windowStart))/float64(window)
+	estimate := float64(sc.prev)*remaining + float64(sc.curr)
+	if estimate+1 > float64(sc.Limit) {
+		return false
+	}
+	sc.curr++
+	return true
+}
@@ -0,0 +1,127 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestSlidingWindowLogRollingWindow(t *testing.T) {
+	const limit = 5
+	sw := &ratelimit.SlidingWindowLog{Duration: 1, Unit: "second", Limit: limit}
+	if err := sw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Stop()
+	var _ ratelimit.RateLimiter = sw
+
+	// Requests arrive at uneven intervals of up to 96ms.
+	at := time.Now()
+	var accepted []time.Time
+	for i := range 400 {
+		at = at.Add(time.Duration(i*i%97) * time.Millisecond)
+		if sw.AcceptAt(at) {
+			accepted = append(accepted, at)
+		}
+	}
+	// The busiest rolling windows end at an accepted request.
+	for _, end := range accepted {
+		n := 0
+		for _, at := range accepted {
+			if at.After(end.Add(-time.Second)) && !at.After(end) {
+				n++
+			}
+		}
+		if n > limit {
+			t.Fatalf("%d requests accepted in the second up to %v, want at most %d", n, end, limit)
+		}
+	}
+	// About 19s of traffic with room for 5 per second.
+	if len(accepted) < 50 {
+		t.Fatalf("only %d of 400 requests accepted", len(accepted))
+	}
+}
+
+func TestSlidingWindowLogBoundary(t *testing.T) {
+	sw := &ratelimit.SlidingWindowLog{Duration: 1, Unit: "second", Limit: 3}
+	if err := sw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Stop()
+	start := time.Now()
+	for i, tc := range []struct {
+		at   time.Duration
+		want bool
+	}{
+		{900 * time.Millisecond, true}, {900 * time.Millisecond, true}, {900 * time.Millisecond, true},
+		// A fixed window would start over at 1s.
+		{1100 * time.Millisecond, false},
+		// The first three leave the window 1s after they were accepted.
+		{1899 * time.Millisecond, false},
+		{1900 * time.Millisecond, true},
+	} {
+		if got := sw.AcceptAt(start.Add(tc.at)); got != tc.want {
+			t.Fatalf("request %d at +%v: accepted %v, want %v", i, tc.at, got, tc.want)
+		}
+	}
+}
+
+// TestSlidingWindowLogLargeLimit checks that memory is not reserved for
+// Limit timestamps up front.
+func TestSlidingWindowLogLargeLimit(t *testing.T) {
+	sw := &ratelimit.SlidingWindowLog{Duration: 1, Unit: "hour", Limit: 1 << 40}
+	if err := sw.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Stop()
+	if !sw.Accept() {
+		t.Fatal("rejected the first request")
+	}
+}
+
+func TestSlidingWindowCounterEstimate(t *testing.T) {
+	sc := &ratelimit.SlidingWindowCounter{Duration: 1, Unit: "second", Limit: 10}
+	if err := sc.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Stop()
+	start := sc.WindowStart()
+	for _, tc := range []struct {
+		at   time.Duration
+		want int
+	}{
+		{100 * time.Millisecond, 10},
+		// All 10 of the previous window still count at its end.
+		{time.Second, 0},
+		// Half of the previous 10, plus 5 more.
+		{1500 * time.Millisecond, 5},
+		// A quarter of the previous 10 leaves room for 2 more than the 5.
+		{1750 * time.Millisecond, 2},
+		// Half of the previous 7, plus 6 more.
+		{2500 * time.Millisecond, 6},
+		// A tenth of the previous 7 leaves room for 3 more than the 6.
+		{2900 * time.Millisecond, 3},
+		// A whole window without a request forgets the earlier ones.
+		{4100 * time.Millisecond, 10},
+	} {
+		got := 0
+		for sc.AcceptAt(start.Add(tc.at)) {
+			got++
+		}
+		if got != tc.want {
+			t.Fatalf("at +%v: accepted %d, want %d", tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestSlidingWindowValidateDuration(t *testing.T) {
+	for _, l := range []ratelimit.RateLimiter{
+		&ratelimit.SlidingWindowLog{Duration: 1 << 40, Unit: "day", Limit: 1},
+		&ratelimit.SlidingWindowCounter{Duration: 1 << 40, Unit: "day", Limit: 1},
+	} {
+		if err := l.Do(); err == nil {
+			t.Fatalf("%T: got nil error for a window longer than a time.Duration", l)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Snapshot is the state of a FixedWindow at one instant.
+type Snapshot struct {
+	Limit       uint64
+	Counter     uint64
+	Remaining   uint64
+	WindowStart time.Time
+	TimeToReset time.Duration
+}
+
+// Snapshot returns the limiter's state, read under a single lock so the
+// fields are consistent with each other even while a reset is happening.
+// Before Do, only Limit and Remaining are set.
+func (fw *FixedWindow) Snapshot() Snapshot {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.snapshotLocked()
+}
+
+// snapshotLocked returns the state for Snapshot. fw.mu must be held.
+func (fw *FixedWindow) snapshotLocked() Snapshot {
+	if !fw.started {
+		return Snapshot{Limit: fw.Limit + fw.Burst, Remaining: fw.Limit + fw.Burst}
+	}
+	now := fw.now()
+	s := Snapshot{
+		Limit:       fw.limitAt(now),
+		Counter:     fw.counterAt(now),
+		WindowStart: fw.windowStartAt(now),
+		TimeToReset: fw.resetInLocked(now),
+	}
+	if s.Counter < s.Limit {
+		s.Remaining = s.Limit - s.Counter
+	}
+	return s
+}
+
+// Stats returns how many windows have ended since Do and when the latest one
+// ended, which is the zero time if none has. A limiter whose count never
+// goes up is likely configured with a longer window than intended.
+func (fw *FixedWindow) Stats() (resets uint64, lastReset time.Time) {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	resets, lastReset = fw.resets, fw.lastReset
+	// With ResetOnFirstRequest the next window only starts with a request,
+	// which calls advanceLocked.
+	if !fw.started || fw.stop || fw.ResetOnFirstRequest {
+		return resets, lastReset
+	}
+	// Windows that ended since the last call to advanceLocked.
+	if start := fw.windowStartAt(fw.now()); start.After(fw.windowStart) {
+		resets += fw.windowsEndedLocked(start)
+		lastReset = start
+	}
+	return resets, lastReset
+}
+
+// String describes the limiter's configuration and current state, e.g.
+// "FixedWindow(limit=100/minute, counter=42, remaining=58)".
+func (fw *FixedWindow) String() string {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	per := fw.Unit
+	switch {
+	case fw.Window != 0:
+		per = fw.Window.String()
+	case fw.Duration != 1:
+		per = fmt.Sprintf("%d %s", fw.Duration, fw.Unit)
+	}
+	if !fw.started {
+		return fmt.Sprintf("FixedWindow(limit=%d/%s, not started)", fw.Limit, per)
+	}
+	s := fw.snapshotLocked()
+	return fmt.Sprintf("FixedWindow(limit=%d/%s, counter=%d, remaining=%d)", s.Limit, per, s.Counter, s.Remaining)
+}
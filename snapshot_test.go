@@ -0,0 +1,131 @@
+package ratelimit_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestSnapshotConsistent(t *testing.T) {
+	const window = time.Millisecond
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(window, 50))
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					fw.Accept()
+				}
+			}
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	// Sample while windows roll over under load.
+	for range 2000 {
+		before := time.Now()
+		s := fw.Snapshot()
+		if s.Limit != 50 {
+			t.Fatalf("limit %d, want 50", s.Limit)
+		}
+		if s.Counter > s.Limit || s.Remaining != s.Limit-s.Counter {
+			t.Fatalf("counter %d and remaining %d do not add up to limit %d", s.Counter, s.Remaining, s.Limit)
+		}
+		if s.TimeToReset <= 0 || s.TimeToReset > window {
+			t.Fatalf("time to reset %v outside (0, %v]", s.TimeToReset, window)
+		}
+		// The window start and time to reset describe the same window,
+		// which contains the time the snapshot was taken.
+		at := s.WindowStart.Add(window - s.TimeToReset)
+		if at.Before(before) || at.After(time.Now()) {
+			t.Fatalf("window start %v and time to reset %v put the snapshot at %v, taken at %v", s.WindowStart, s.TimeToReset, at, before)
+		}
+	}
+}
+
+func TestSnapshotBeforeDo(t *testing.T) {
+	fw := &ratelimit.FixedWindow{Window: time.Minute, Limit: 3, Burst: 1}
+	if s := fw.Snapshot(); s.Limit != 4 || s.Remaining != 4 || s.Counter != 0 {
+		t.Fatalf("snapshot %+v before Do", s)
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, tc := range []struct {
+		fw   *ratelimit.FixedWindow
+		want string
+	}{
+		{&ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 100}, "FixedWindow(limit=100/minute, counter=42, remaining=58)"},
+		{&ratelimit.FixedWindow{Duration: 5, Unit: "second", Limit: 100}, "FixedWindow(limit=100/5 second, counter=42, remaining=58)"},
+		{&ratelimit.FixedWindow{Window: 90 * time.Second, Limit: 100}, "FixedWindow(limit=100/1m30s, counter=42, remaining=58)"},
+	} {
+		fw := startFixedWindow(t, tc.fw)
+		fw.AcceptN(42)
+		if got := fw.String(); got != tc.want {
+			t.Fatalf("got %q, want %q", got, tc.want)
+		}
+	}
+	if got, want := (&ratelimit.FixedWindow{Duration: 1, Unit: "hour", Limit: 3}).String(), "FixedWindow(limit=3/hour, not started)"; got != want {
+		t.Fatalf("before Do: got %q, want %q", got, want)
+	}
+}
+
+func TestStringConcurrentWithSetters(t *testing.T) {
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Duration: 1, Unit: "minute", Limit: 100})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			if i%2 == 0 {
+				fw.SetUnit("second")
+			} else {
+				fw.SetWindow(time.Duration(i) * time.Second)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			_ = fw.String()
+		}
+	}()
+	wg.Wait()
+}
+
+func TestStats(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 1, Clock: clock})
+	if resets, last := fw.Stats(); resets != 0 || !last.IsZero() {
+		t.Fatalf("got %d resets, last %v, want none", resets, last)
+	}
+	clock.Add(90 * time.Second)
+	if resets, last := fw.Stats(); resets != 1 || !last.Equal(start.Add(time.Minute)) {
+		t.Fatalf("got %d resets, last %v, want 1 at %v", resets, last, start.Add(time.Minute))
+	}
+	// Windows that pass without a request count too.
+	fw.Accept()
+	clock.Add(3 * time.Minute)
+	if resets, last := fw.Stats(); resets != 4 || !last.Equal(start.Add(4*time.Minute)) {
+		t.Fatalf("got %d resets, last %v, want 4 at %v", resets, last, start.Add(4*time.Minute))
+	}
+}
+
+func TestStatsBeforeDo(t *testing.T) {
+	var fw ratelimit.FixedWindow
+	if resets, last := fw.Stats(); resets != 0 || !last.IsZero() {
+		t.Fatalf("got %d resets, last %v before Do", resets, last)
+	}
+}
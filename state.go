@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"time"
+)
+
+// savedState is the gob form of a FixedWindow's current window.
+type savedState struct {
+	Period      time.Duration
+	WindowStart time.Time
+	Counter     uint64
+}
+
+// SaveState writes the current window's start and count to w, so that a
+// new process can carry on from them with LoadState instead of handing out
+// a fresh quota. It returns an error if the limiter has not been started.
+func (fw *FixedWindow) SaveState(w io.Writer) error {
+	fw.mu.RLock()
+	if !fw.started {
+		fw.mu.RUnlock()
+		return errNotStarted
+	}
+	now := fw.now()
+	st := savedState{
+		Period:      fw.period,
+		WindowStart: fw.windowStartAt(now),
+		Counter:     fw.counterAt(now),
+	}
+	fw.mu.RUnlock()
+	return gob.NewEncoder(w).Encode(st)
+}
+
+// LoadState restores a window written by SaveState. It may be called before
+// Do, in which case Do applies it, or on a running limiter. The saved count
+// is only restored if its window has not ended yet; otherwise the limiter
+// starts with a fresh window as usual. State saved with a different window
+// length is rejected.
+func (fw *FixedWindow) LoadState(r io.Reader) error {
+	var st savedState
+	if err := gob.NewDecoder(r).Decode(&st); err != nil {
+		return err
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.started {
+		if err := st.check(fw.period); err != nil {
+			return err
+		}
+		fw.restoreLocked(st, fw.now())
+		return nil
+	}
+	d, err := fw.duration()
+	if err != nil {
+		return err
+	}
+	if err := st.check(d); err != nil {
+		return err
+	}
+	fw.saved = &st
+	return nil
+}
+
+func (st savedState) check(period time.Duration) error {
+	if st.Period != period {
+		return fmt.Errorf("saved state is for a %v window, not %v", st.Period, period)
+	}
+	return nil
+}
+
+// restoreLocked makes st the current window if it is still in progress at
+// now. fw.mu must be held.
+func (fw *FixedWindow) restoreLocked(st savedState, now time.Time) {
+	if st.WindowStart.After(now) || !now.Before(st.WindowStart.Add(st.Period)) {
+		return
+	}
+	fw.windowStart = st.WindowStart
+	fw.counter.Store(st.Counter)
+	fw.reserved = nil
+	fw.publishLocked()
+	fw.wake()
+}
@@ -0,0 +1,57 @@
+package ratelimit_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestSaveLoadState(t *testing.T) {
+	clock := newFakeClock()
+	old := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 5, Clock: clock})
+	old.AcceptN(3)
+	clock.Add(20 * time.Second)
+	var buf bytes.Buffer
+	if err := old.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh limiter carries on with the saved window.
+	clock.Add(10 * time.Second)
+	fresh := &ratelimit.FixedWindow{Window: time.Minute, Limit: 5, Clock: clock}
+	if err := fresh.LoadState(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	startFixedWindow(t, fresh)
+	if got := fresh.Remaining(); got != 2 {
+		t.Fatalf("remaining %d, want 2", got)
+	}
+	if got := fresh.ResetIn(); got != 30*time.Second {
+		t.Fatalf("reset in %v, want 30s", got)
+	}
+
+	// Once the saved window has ended the state is ignored.
+	clock.Add(time.Minute)
+	late := &ratelimit.FixedWindow{Window: time.Minute, Limit: 5, Clock: clock}
+	if err := late.LoadState(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if got := startFixedWindow(t, late).Remaining(); got != 5 {
+		t.Fatalf("remaining %d after the saved window ended, want 5", got)
+	}
+
+	// State for another window length is rejected.
+	other := &ratelimit.FixedWindow{Window: time.Hour, Limit: 5}
+	if err := other.LoadState(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatal("loaded state saved for a different window length")
+	}
+}
+
+func TestSaveStateBeforeDo(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ratelimit.NewFixedWindow(time.Minute, 1).SaveState(&buf); err == nil {
+		t.Fatal("saved the state of a limiter that was not started")
+	}
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Snapshotter is implemented by limiters that can report their state as a
+// Snapshot, such as FixedWindow.
+type Snapshotter interface {
+	Snapshot() Snapshot
+}
+
+// status is the JSON document written by StatusHandler.
+type status struct {
+	Limit       uint64    `json:"limit"`
+	Counter     uint64    `json:"counter"`
+	Remaining   uint64    `json:"remaining"`
+	WindowStart time.Time `json:"window_start"`
+	ResetAt     time.Time `json:"reset_at"`
+}
+
+// StatusHandler returns a handler that reports the state of rl as JSON, e.g.
+//
+//	{"limit":100,"counter":42,"remaining":58,
+//	 "window_start":"2024-05-01T12:00:00Z","reset_at":"2024-05-01T12:01:00Z"}
+//
+// It is meant to be mounted at an operator-only path such as
+// /ratelimit/status. Wrappers such as LoggingLimiter report the state of
+// the limiter they wrap; limiters with no Snapshotter inside get 501 Not
+// Implemented.
+func StatusHandler(rl Limiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := As[Snapshotter](rl)
+		if !ok {
+			http.Error(w, "limiter does not report its state", http.StatusNotImplemented)
+			return
+		}
+		writeStatus(w, s)
+	}
+}
+
+// KeyedStatusHandler is like StatusHandler but reports the limiter of the
+// key given in the "key" query parameter. Keys that have not been seen, or
+// have been evicted, get 404 Not Found.
+func KeyedStatusHandler(kl *KeyedLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "missing key parameter", http.StatusBadRequest)
+			return
+		}
+		fw, ok := kl.lookup(key)
+		if !ok {
+			http.Error(w, "no limiter for key", http.StatusNotFound)
+			return
+		}
+		writeStatus(w, fw)
+	}
+}
+
+func writeStatus(w http.ResponseWriter, s Snapshotter) {
+	snap := s.Snapshot()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status{
+		Limit:       snap.Limit,
+		Counter:     snap.Counter,
+		Remaining:   snap.Remaining,
+		WindowStart: snap.WindowStart,
+		ResetAt:     time.Now().Add(snap.TimeToReset).Truncate(time.Millisecond),
+	})
+}
@@ -0,0 +1,89 @@
+package ratelimit_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// statusDoc is the JSON written by StatusHandler.
+type statusDoc struct {
+	Limit       uint64    `json:"limit"`
+	Counter     uint64    `json:"counter"`
+	Remaining   uint64    `json:"remaining"`
+	WindowStart time.Time `json:"window_start"`
+	ResetAt     time.Time `json:"reset_at"`
+}
+
+func decodeStatus(t *testing.T, rec *httptest.ResponseRecorder) statusDoc {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("Content-Type %q, want application/json", got)
+	}
+	var doc statusDoc
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestStatusHandler(t *testing.T) {
+	before := time.Now()
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 5))
+	fw.AcceptN(2)
+	doc := decodeStatus(t, serve(ratelimit.StatusHandler(fw), "/ratelimit/status"))
+	if doc.Limit != 5 || doc.Counter != 2 || doc.Remaining != 3 {
+		t.Fatalf("got limit %d, counter %d, remaining %d, want 5, 2 and 3", doc.Limit, doc.Counter, doc.Remaining)
+	}
+	if doc.WindowStart.Before(before) || doc.WindowStart.After(time.Now()) {
+		t.Fatalf("window start %v, want when Do was called", doc.WindowStart)
+	}
+	// reset_at is truncated to the millisecond.
+	if d := doc.ResetAt.Sub(doc.WindowStart) - time.Hour; d < -2*time.Millisecond || d > 2*time.Millisecond {
+		t.Fatalf("reset at %v, want an hour after %v", doc.ResetAt, doc.WindowStart)
+	}
+}
+
+func TestStatusHandlerThroughWrapper(t *testing.T) {
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 5))
+	fw.Accept()
+	rl := ratelimit.LoggingLimiter(fw, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	doc := decodeStatus(t, serve(ratelimit.StatusHandler(rl), "/ratelimit/status"))
+	if doc.Limit != 5 || doc.Counter != 1 || doc.Remaining != 4 {
+		t.Fatalf("got limit %d, counter %d, remaining %d, want 5, 1 and 4", doc.Limit, doc.Counter, doc.Remaining)
+	}
+}
+
+func TestStatusHandlerNotImplemented(t *testing.T) {
+	if rec := serve(ratelimit.StatusHandler(ratelimit.NoOp{}), "/"); rec.Code != http.StatusNotImplemented {
+		t.Fatalf("status %d for NoOp, want 501", rec.Code)
+	}
+}
+
+func TestKeyedStatusHandler(t *testing.T) {
+	kl := startKeyed(t, &ratelimit.KeyedLimiter{Window: time.Hour, Limit: 3})
+	kl.Accept("alice")
+	h := ratelimit.KeyedStatusHandler(kl)
+	doc := decodeStatus(t, serve(h, "/?key=alice"))
+	if doc.Limit != 3 || doc.Counter != 1 || doc.Remaining != 2 {
+		t.Fatalf("got limit %d, counter %d, remaining %d, want 3, 1 and 2", doc.Limit, doc.Counter, doc.Remaining)
+	}
+	if rec := serve(h, "/?key=bob"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown key: status %d, want 404", rec.Code)
+	}
+	if kl.Has("bob") {
+		t.Fatal("status request created a limiter for the key")
+	}
+	if rec := serve(h, "/"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing key: status %d, want 400", rec.Code)
+	}
+}
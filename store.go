@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps window counters on behalf of a FixedWindow, so that the
+// count can be shared or live outside the process.
+//
+// Incr takes the number of units to add, so that FixedWindow.AcceptN costs
+// a single round trip. Stores count every request, including rejected
+// ones, the way a Redis INCR does. A FixedWindow backed by a Store
+// therefore stays rejected for the rest of the window once it goes over
+// the limit, and AcceptN is not all-or-nothing.
+type Store interface {
+	// Incr adds n to the counter for key and returns the new total. A key
+	// that does not exist, or has expired, starts again from zero and
+	// expires after ttl. FixedWindow passes the time left in its current
+	// window, so the key ends with the window that created it.
+	Incr(key string, n uint64, ttl time.Duration) (uint64, error)
+	// Reset deletes the counter for key.
+	Reset(key string)
+}
+
+// MemoryStore is an in-process Store, e.g. to share one count between
+// several FixedWindows with different limits, or to stand in for a remote
+// Store in tests. The zero value is ready to use.
+//
+// Expired counters are replaced on the next Incr for the same key rather
+// than swept, so keys that are never used again are kept until Reset.
+type MemoryStore struct {
+	// Clock, if set, replaces the system clock for expiring counters. Give
+	// it the same Clock as the limiters using the store.
+	Clock Clock
+
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+}
+
+type memoryCounter struct {
+	count   uint64
+	expires time.Time
+}
+
+// Incr implements Store.
+func (ms *MemoryStore) Incr(key string, n uint64, ttl time.Duration) (uint64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var now time.Time
+	if ms.Clock != nil {
+		now = ms.Clock.Now()
+	} else {
+		now = systemClock{}.Now()
+	}
+	c, ok := ms.counters[key]
+	if !ok || !now.Before(c.expires) {
+		if ms.counters == nil {
+			ms.counters = make(map[string]*memoryCounter)
+		}
+		c = &memoryCounter{expires: now.Add(ttl)}
+		ms.counters[key] = c
+	}
+	c.count += n
+	return c.count, nil
+}
+
+// Reset implements Store.
+func (ms *MemoryStore) Reset(key string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.counters, key)
+}
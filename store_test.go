@@ -0,0 +1,158 @@
+package ratelimit_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// mockStore records the calls made to it and keeps a single count.
+type mockStore struct {
+	count  uint64
+	keys   []string
+	ns     []uint64
+	ttl    time.Duration
+	resets int
+}
+
+func (m *mockStore) Incr(key string, n uint64, ttl time.Duration) (uint64, error) {
+	m.keys = append(m.keys, key)
+	m.ns = append(m.ns, n)
+	m.ttl = ttl
+	m.count += n
+	return m.count, nil
+}
+
+func (m *mockStore) Reset(key string) {
+	m.resets++
+	m.count = 0
+}
+
+func TestFixedWindowStore(t *testing.T) {
+	clock := newFakeClock()
+	store := &mockStore{}
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 3, Store: store, Key: "client", Clock: clock})
+	if !fw.AcceptN(2) {
+		t.Fatal("rejected within the limit")
+	}
+	if store.ttl != time.Minute {
+		t.Fatalf("Incr ttl %v at the start of the window, want 1m", store.ttl)
+	}
+	clock.Add(20 * time.Second)
+	if !fw.Accept() {
+		t.Fatal("rejected within the limit")
+	}
+	if fw.Accept() {
+		t.Fatal("accepted over the limit")
+	}
+	if store.count != 4 {
+		t.Fatalf("store count %d, want 4: rejected requests count too", store.count)
+	}
+	for i, key := range store.keys {
+		if key != "client" {
+			t.Fatalf("Incr %d used key %q, want client", i, key)
+		}
+	}
+	if got := store.ns; len(got) != 3 || got[0] != 2 || got[1] != 1 || got[2] != 1 {
+		t.Fatalf("Incr called with n = %v, want [2 1 1]", got)
+	}
+	if store.ttl != 40*time.Second {
+		t.Fatalf("Incr ttl %v 20s into the window, want 40s", store.ttl)
+	}
+	if got := fw.Remaining(); got != 0 {
+		t.Fatalf("remaining %d, want 0", got)
+	}
+
+	fw.Reset()
+	if store.resets != 1 {
+		t.Fatalf("Reset called the store %d times, want 1", store.resets)
+	}
+	if !fw.Accept() {
+		t.Fatal("rejected after Reset")
+	}
+}
+
+func TestMemoryStoreShared(t *testing.T) {
+	clock := newFakeClock()
+	store := &ratelimit.MemoryStore{Clock: clock}
+	a := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 2, Store: store, Key: "k", Clock: clock})
+	b := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 2, Store: store, Key: "k", Clock: clock})
+	if !a.Accept() || !b.Accept() {
+		t.Fatal("rejected within the shared limit")
+	}
+	if a.Accept() {
+		t.Fatal("accepted over the shared limit")
+	}
+	clock.Add(time.Minute)
+	if !b.Accept() {
+		t.Fatal("store count did not expire with the clock")
+	}
+}
+
+// TestMemoryStoreFollowsWindow fills the window near its end and checks
+// that the Store's count ends with it rather than a full window later.
+func TestMemoryStoreFollowsWindow(t *testing.T) {
+	clock := newFakeClock()
+	store := &ratelimit.MemoryStore{Clock: clock}
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 2, Store: store, Key: "k", Clock: clock})
+	clock.Add(50 * time.Second)
+	if !fw.AcceptN(2) || fw.Accept() {
+		t.Fatal("the window does not hold exactly its limit")
+	}
+	clock.Add(15 * time.Second)
+	if got := fw.Remaining(); got != 2 {
+		t.Fatalf("remaining %d in the next window, want 2", got)
+	}
+	if got := fw.RetryAfter(); got != 0 {
+		t.Fatalf("retry after %v in the next window, want 0", got)
+	}
+	if !fw.AcceptN(2) {
+		t.Fatal("the next window rejected a request its Remaining allowed")
+	}
+	if got := fw.RetryAfter(); got != 55*time.Second {
+		t.Fatalf("retry after %v with the window full, want 55s", got)
+	}
+	clock.Add(55 * time.Second)
+	if !fw.Accept() {
+		t.Fatal("the Store's count outlived the window")
+	}
+}
+
+// failingStore fails every call.
+type failingStore struct{}
+
+var errStoreDown = errors.New("store down")
+
+func (failingStore) Incr(string, uint64, time.Duration) (uint64, error) { return 0, errStoreDown }
+
+func (failingStore) Reset(string) {}
+
+func TestFixedWindowStoreError(t *testing.T) {
+	for _, failOpen := range []bool{false, true} {
+		fw := &ratelimit.FixedWindow{Window: time.Minute, Limit: 1, Store: failingStore{}, FailOpen: failOpen, EventBuffer: 8}
+		if err := fw.Do(); err != nil {
+			t.Fatal(err)
+		}
+		for i := range 2 {
+			if got := fw.Accept(); got != failOpen {
+				t.Fatalf("FailOpen %v, request %d: got %v, want %v", failOpen, i, got, failOpen)
+			}
+		}
+		fw.Stop()
+		var storeErrors int
+		for e := range fw.Events() {
+			if e.Type != ratelimit.EventStoreError {
+				continue
+			}
+			if !errors.Is(e.Err, errStoreDown) {
+				t.Fatalf("FailOpen %v: store error event carries %v, want %v", failOpen, e.Err, errStoreDown)
+			}
+			storeErrors++
+		}
+		if storeErrors != 2 {
+			t.Fatalf("FailOpen %v: %d store error events, want 2", failOpen, storeErrors)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// SwappableLimiter forwards to a RateLimiter that can be replaced at any
+// time with Swap, e.g. to apply a reloaded configuration or switch a
+// FixedWindow for a TokenBucket without restarting. Accept costs a single
+// atomic load on top of the current limiter.
+type SwappableLimiter struct {
+	current atomic.Pointer[limiterBox]
+}
+
+type limiterBox struct {
+	rl RateLimiter
+}
+
+// NewSwappableLimiter returns a SwappableLimiter forwarding to rl.
+func NewSwappableLimiter(rl RateLimiter) *SwappableLimiter {
+	s := &SwappableLimiter{}
+	s.Swap(rl)
+	return s
+}
+
+// Swap makes rl the current limiter and returns the previous one, which the
+// caller may want to Stop. rl should already be started.
+func (s *SwappableLimiter) Swap(rl RateLimiter) RateLimiter {
+	old := s.current.Swap(&limiterBox{rl: rl})
+	if old == nil {
+		return nil
+	}
+	return old.rl
+}
+
+// Load returns the current limiter.
+func (s *SwappableLimiter) Load() RateLimiter {
+	if b := s.current.Load(); b != nil {
+		return b.rl
+	}
+	return nil
+}
+
+// Validate validates the current limiter.
+func (s *SwappableLimiter) Validate() error {
+	rl := s.Load()
+	if rl == nil {
+		return errors.New("no limiter set")
+	}
+	return rl.Validate()
+}
+
+// Do starts the current limiter.
+func (s *SwappableLimiter) Do() error {
+	rl := s.Load()
+	if rl == nil {
+		return errors.New("no limiter set")
+	}
+	return rl.Do()
+}
+
+// Accept asks the current limiter. It rejects if none is set.
+func (s *SwappableLimiter) Accept() bool {
+	rl := s.Load()
+	return rl != nil && rl.Accept()
+}
+
+// AcceptWithReason asks the current limiter, with a reason if it can give
+// one. It rejects with ReasonStopped if none is set.
+func (s *SwappableLimiter) AcceptWithReason() (bool, Reason) {
+	rl := s.Load()
+	if rl == nil {
+		return false, ReasonStopped
+	}
+	return AcceptWithReason(rl)
+}
+
+// Unwrap returns the current limiter, so that HTTPMiddleware finds its
+// WindowReporter and RetryReporter.
+func (s *SwappableLimiter) Unwrap() Limiter {
+	if rl := s.Load(); rl != nil {
+		return rl
+	}
+	return nil
+}
+
+// Stop stops the current limiter.
+func (s *SwappableLimiter) Stop() {
+	if rl := s.Load(); rl != nil {
+		rl.Stop()
+	}
+}
@@ -0,0 +1,60 @@
+package ratelimit_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestSwappableLimiterSwap(t *testing.T) {
+	first := startFixedWindow(t, ratelimit.NewFixedWindow(time.Hour, 1))
+	s := ratelimit.NewSwappableLimiter(first)
+	if !s.Accept() || s.Accept() {
+		t.Fatal("did not forward to the first limiter")
+	}
+	if old := s.Swap(ratelimit.NoOp{}); old != first {
+		t.Fatalf("Swap returned %v, want the first limiter", old)
+	}
+	if !s.Accept() {
+		t.Fatal("did not forward to the new limiter")
+	}
+	if s.Load() != (ratelimit.NoOp{}) {
+		t.Fatalf("Load returned %v, want NoOp", s.Load())
+	}
+}
+
+func TestSwappableLimiterEmpty(t *testing.T) {
+	var s ratelimit.SwappableLimiter
+	if s.Accept() {
+		t.Fatal("accepted with no limiter set")
+	}
+	if err := s.Validate(); err == nil {
+		t.Fatal("Validate: got nil error with no limiter set")
+	}
+	if err := s.Do(); err == nil {
+		t.Fatal("Do: got nil error with no limiter set")
+	}
+	s.Stop()
+}
+
+func TestSwappableLimiterConcurrentSwap(t *testing.T) {
+	s := ratelimit.NewSwappableLimiter(ratelimit.NoOp{})
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 1000 {
+				if (i+j)%2 == 0 {
+					s.Swap(ratelimit.NoOp{})
+				} else {
+					s.Swap(ratelimit.AlwaysDeny{})
+				}
+				s.Accept()
+			}
+		}()
+	}
+	wg.Wait()
+}
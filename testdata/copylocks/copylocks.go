@@ -0,0 +1,14 @@
+// Package copylocks copies limiters by value, which go vet must report.
+// It is used by TestVetReportsCopies and is not part of the module's API.
+package copylocks
+
+import "github.com/govi230/ratelimit"
+
+func fixedWindow(fw ratelimit.FixedWindow) ratelimit.FixedWindow {
+	return fw
+}
+
+func keyed(kl *ratelimit.KeyedLimiter) {
+	copied := *kl
+	_ = copied
+}
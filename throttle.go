@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+)
+
+// WaitNer is implemented by limiters that can block until several units
+// fit at once, such as FixedWindow.
+type WaitNer interface {
+	WaitN(ctx context.Context, n uint64) error
+}
+
+// ThrottledReader returns a reader that paces reads from r through rl, with
+// every unit of rl standing for bytesPerUnit bytes. For example, a
+// FixedWindow of 64 per second with bytesPerUnit 1024 caps throughput at
+// 64 KiB/s. A bytesPerUnit of zero is treated as one.
+//
+// If rl implements WaitNer, each Read waits for as many units as it needs,
+// up to a window's worth if rl is a WindowReporter. Otherwise each Read
+// takes one unit, waiting for it if rl is a Waiter and failing with
+// ErrLimited if it is rejected. Units for bytes that r did not return are
+// given back if rl is a Refunder.
+func ThrottledReader(r io.Reader, rl Limiter, bytesPerUnit uint64) io.Reader {
+	return &throttledReader{r: r, rl: rl, bytesPerUnit: max(bytesPerUnit, 1)}
+}
+
+type throttledReader struct {
+	r            io.Reader
+	rl           Limiter
+	bytesPerUnit uint64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return t.r.Read(p)
+	}
+	n, err := t.acquire(uint64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	if size := n * t.bytesPerUnit; size < uint64(len(p)) {
+		p = p[:size]
+	}
+	got, err := t.r.Read(p)
+	if used := ceilDiv(uint64(got), t.bytesPerUnit); used < n {
+		if rf, ok := t.rl.(Refunder); ok {
+			rf.Refund(n - used)
+		}
+	}
+	return got, err
+}
+
+// acquire takes units for up to size bytes and returns how many it took.
+func (t *throttledReader) acquire(size uint64) (uint64, error) {
+	ctx := context.Background()
+	if wn, ok := t.rl.(WaitNer); ok {
+		n := ceilDiv(size, t.bytesPerUnit)
+		if wr, ok := t.rl.(WindowReporter); ok {
+			n = min(n, max(wr.WindowLimit(), 1))
+		}
+		return n, wn.WaitN(ctx, n)
+	}
+	if w, ok := t.rl.(Waiter); ok {
+		return 1, w.Wait(ctx)
+	}
+	if !t.rl.Accept() {
+		return 0, ErrLimited
+	}
+	return 1, nil
+}
+
+func ceilDiv(a, b uint64) uint64 {
+	if a == 0 {
+		return 0
+	}
+	return (a-1)/b + 1
+}
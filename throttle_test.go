@@ -0,0 +1,38 @@
+package ratelimit_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestThrottledReaderRate(t *testing.T) {
+	// 4 units of 256 bytes every 50ms is 1 KiB per window. Reading 1 KiB at
+	// a time, 4 KiB takes the first window and three more.
+	const window = 50 * time.Millisecond
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: window, Limit: 4})
+	data := bytes.Repeat([]byte("0123456789abcdef"), 256)
+	r := ratelimit.ThrottledReader(bytes.NewReader(data), fw, 256)
+	var got []byte
+	buf := make([]byte, 1024)
+	start := time.Now()
+	for len(got) < len(data) {
+		n, err := r.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			t.Fatalf("after %d bytes: %v", len(got), err)
+		}
+	}
+	elapsed := time.Since(start)
+	if !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes that differ from the %d written", len(got), len(data))
+	}
+	if want := 3 * window; elapsed < want {
+		t.Fatalf("read 4 KiB in %v, want at least %v at 1 KiB per %v", elapsed, want, window)
+	}
+	if limit := 3*window + 500*time.Millisecond; elapsed > limit {
+		t.Fatalf("read 4 KiB in %v, want close to %v", elapsed, 3*window)
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import "fmt"
+
+// TieredLimiter returns a limiter that asks local before distributed, so
+// that requests local rejects never cost a round trip to a shared store
+// such as Redis. A request is accepted only if both accept it; if
+// distributed rejects, the slot taken in local is given back when local is
+// a Refunder.
+//
+// local would typically allow a little more than this process's share of
+// the distributed limit, so that it only turns away the excess.
+//
+// The returned limiter unwraps to distributed, so HTTPMiddleware reports
+// the shared window in its X-RateLimit-* headers.
+func TieredLimiter(local, distributed RateLimiter) RateLimiter {
+	return &tieredLimiter{local: local, distributed: distributed}
+}
+
+type tieredLimiter struct {
+	local       RateLimiter
+	distributed RateLimiter
+}
+
+func (t *tieredLimiter) Validate() error {
+	if err := t.local.Validate(); err != nil {
+		return fmt.Errorf("local limiter: %w", err)
+	}
+	if err := t.distributed.Validate(); err != nil {
+		return fmt.Errorf("distributed limiter: %w", err)
+	}
+	return nil
+}
+
+// Do starts both limiters, stopping local again if distributed fails to
+// start.
+func (t *tieredLimiter) Do() error {
+	if err := t.local.Do(); err != nil {
+		return fmt.Errorf("local limiter: %w", err)
+	}
+	if err := t.distributed.Do(); err != nil {
+		t.local.Stop()
+		return fmt.Errorf("distributed limiter: %w", err)
+	}
+	return nil
+}
+
+func (t *tieredLimiter) Accept() bool {
+	ok, _ := t.AcceptWithReason()
+	return ok
+}
+
+func (t *tieredLimiter) AcceptWithReason() (bool, Reason) {
+	if ok, reason := AcceptWithReason(t.local); !ok {
+		return false, reason
+	}
+	ok, reason := AcceptWithReason(t.distributed)
+	if !ok {
+		if r, isRefunder := t.local.(Refunder); isRefunder {
+			r.Refund(1)
+		}
+	}
+	return ok, reason
+}
+
+func (t *tieredLimiter) Unwrap() Limiter { return t.distributed }
+
+func (t *tieredLimiter) Stop() {
+	t.local.Stop()
+	t.distributed.Stop()
+}
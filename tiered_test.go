@@ -0,0 +1,65 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// startTiered starts a TieredLimiter over local and distributed and stops it
+// when the test ends.
+func startTiered(t *testing.T, local, distributed *ratelimit.FixedWindow) ratelimit.RateLimiter {
+	t.Helper()
+	tl := ratelimit.TieredLimiter(local, distributed)
+	if err := tl.Do(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tl.Stop)
+	return tl
+}
+
+func TestTieredLocalShortCircuits(t *testing.T) {
+	local := ratelimit.NewFixedWindow(time.Hour, 1)
+	distributed := ratelimit.NewFixedWindow(time.Hour, 10)
+	tl := startTiered(t, local, distributed)
+	if !tl.Accept() {
+		t.Fatal("first request rejected")
+	}
+	for range 3 {
+		if tl.Accept() {
+			t.Fatal("accepted over the local limit")
+		}
+	}
+	if got := distributed.Counter(); got != 1 {
+		t.Fatalf("distributed counted %d requests, want 1: local rejections reached it", got)
+	}
+}
+
+func TestTieredDistributedRejects(t *testing.T) {
+	local := ratelimit.NewFixedWindow(time.Hour, 10)
+	distributed := ratelimit.NewFixedWindow(time.Hour, 2)
+	tl := startTiered(t, local, distributed)
+	for i, want := range []bool{true, true, false, false} {
+		if got := tl.Accept(); got != want {
+			t.Fatalf("request %d: got %v, want %v", i, got, want)
+		}
+	}
+	// The slots local gave the two rejected requests were refunded.
+	if got := local.Counter(); got != 2 {
+		t.Fatalf("local counter %d, want 2", got)
+	}
+
+	// A reason is passed on from whichever limiter decided.
+	ra := tl.(ratelimit.ReasonAccepter)
+	if ok, reason := ra.AcceptWithReason(); ok || reason != ratelimit.ReasonLimitReached {
+		t.Fatalf("got %v, %v, want false, %v", ok, reason, ratelimit.ReasonLimitReached)
+	}
+	distributed.Stop()
+	if ok, reason := ra.AcceptWithReason(); ok || reason != ratelimit.ReasonStopped {
+		t.Fatalf("distributed stopped: got %v, %v, want false, %v", ok, reason, ratelimit.ReasonStopped)
+	}
+	if got := local.Counter(); got != 2 {
+		t.Fatalf("local counter %d after more rejections, want 2", got)
+	}
+}
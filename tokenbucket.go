@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// TokenBucket holds up to Capacity tokens and refills RefillRate tokens per
+// Unit. Each accepted request consumes one token.
+//
+// Tokens are refilled continuously from the elapsed time rather than at
+// window boundaries, so unlike FixedWindow a client cannot spend a full
+// window's worth of requests on each side of a boundary.
+type TokenBucket struct {
+	Capacity   uint64
+	RefillRate uint64
+	Unit       string
+
+	tokens float64
+	last   time.Time
+	mu     *sync.Mutex
+	stop   bool
+}
+
+// Validate checks that the capacity, refill rate and unit are usable.
+func (tb *TokenBucket) Validate() error {
+	if tb.Capacity == 0 {
+		return errors.New("capacity must be greater than zero")
+	}
+	if tb.RefillRate == 0 {
+		return errors.New("refill rate must be greater than zero")
+	}
+	return validateUnit(tb.Unit)
+}
+
+// Do validates the configuration and fills the bucket.
+func (tb *TokenBucket) Do() error {
+	if err := tb.Validate(); err != nil {
+		return err
+	}
+	if tb.mu == nil {
+		tb.mu = &sync.Mutex{}
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens = float64(tb.Capacity)
+	tb.last = time.Now()
+	return nil
+}
+
+// Accept refills the bucket for the time elapsed since the previous call and
+// consumes a token if one is available.
+func (tb *TokenBucket) Accept() bool {
+	return tb.acceptAt(time.Now())
+}
+
+func (tb *TokenBucket) acceptAt(now time.Time) bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if tb.stop {
+		return false
+	}
+	tb.refill(now)
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+// Refund puts n tokens back in the bucket, up to Capacity.
+func (tb *TokenBucket) Refund(n uint64) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens = min(tb.tokens+float64(n), float64(tb.Capacity))
+}
+
+// Stop makes Accept reject every request. It may be called before Do.
+func (tb *TokenBucket) Stop() {
+	if tb.mu == nil {
+		tb.stop = true
+		return
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.stop = true
+}
+
+func (tb *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(tb.last)
+	if elapsed <= 0 {
+		return
+	}
+	unit, _ := unitDuration(tb.Unit)
+	tb.tokens += float64(tb.RefillRate) * float64(elapsed) / float64(unit)
+	if capacity := float64(tb.Capacity); tb.tokens > capacity {
+		tb.tokens = capacity
+	}
+	tb.last = now
+}
@@ -0,0 +1,37 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// TestTokenBucketBurstAcrossEdge checks that, unlike a FixedWindow, a
+// TokenBucket does not allow a second full burst straight after the first:
+// tokens come back at RefillRate, not all at once.
+func TestTokenBucketBurstAcrossEdge(t *testing.T) {
+	tb := &ratelimit.TokenBucket{Capacity: 3, RefillRate: 10, Unit: "second"}
+	if err := tb.Do(); err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Stop()
+	var _ ratelimit.RateLimiter = tb
+
+	start := time.Now()
+	for i, tc := range []struct {
+		at   time.Duration
+		want bool
+	}{
+		// A full bucket allows a burst of Capacity.
+		{0, true}, {0, true}, {0, true}, {0, false},
+		// 100ms later only one token has come back.
+		{100 * time.Millisecond, true}, {100 * time.Millisecond, false},
+		// After a long idle period the bucket holds at most Capacity.
+		{time.Hour, true}, {time.Hour, true}, {time.Hour, true}, {time.Hour, false},
+	} {
+		if got := tb.AcceptAt(start.Add(tc.at)); got != tc.want {
+			t.Fatalf("request %d at +%v: accepted %v, want %v", i, tc.at, got, tc.want)
+		}
+	}
+}
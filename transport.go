@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Waiter is implemented by limiters that can block until a request fits,
+// such as FixedWindow.
+type Waiter interface {
+	Wait(ctx context.Context) error
+}
+
+// ErrLimited is returned by the RoundTripper when a request is rejected by a
+// limiter that cannot wait.
+var ErrLimited = errors.New("rate limit exceeded")
+
+// RoundTripper returns an http.RoundTripper that paces outbound requests
+// through rl before handing them to next. A nil next uses
+// http.DefaultTransport.
+//
+// If rl implements Waiter each request blocks until it fits, or until its
+// context is done. Otherwise a rejected request fails with ErrLimited.
+func RoundTripper(next http.RoundTripper, rl Limiter) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &roundTripper{next: next, rl: rl}
+}
+
+type roundTripper struct {
+	next http.RoundTripper
+	rl   Limiter
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if w, ok := rt.rl.(Waiter); ok {
+		if err := w.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	} else if !rt.rl.Accept() {
+		return nil, ErrLimited
+	}
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,56 @@
+package ratelimit_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+// countingTransport answers every request with an empty 200 and counts them.
+type countingTransport struct{ calls int }
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func TestRoundTripperPaces(t *testing.T) {
+	const window = 50 * time.Millisecond
+	fw := startFixedWindow(t, ratelimit.NewFixedWindow(window, 2))
+	next := &countingTransport{}
+	client := &http.Client{Transport: ratelimit.RoundTripper(next, fw)}
+
+	// Six requests at two per window need the first window and two more.
+	start := time.Now()
+	for i := range 6 {
+		resp, err := client.Get("http://example.test/")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 2*window {
+		t.Fatalf("6 requests took %v, want at least %v", elapsed, 2*window)
+	}
+	if next.calls != 6 {
+		t.Fatalf("%d requests reached the transport, want 6", next.calls)
+	}
+}
+
+func TestRoundTripperRejects(t *testing.T) {
+	next := &countingTransport{}
+	rt := ratelimit.RoundTripper(next, ratelimit.AlwaysDeny{})
+	_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://example.test/", nil))
+	if !errors.Is(err, ratelimit.ErrLimited) {
+		t.Fatalf("RoundTrip returned %v, want ErrLimited", err)
+	}
+	if next.calls != 0 {
+		t.Fatal("rejected request reached the transport")
+	}
+}
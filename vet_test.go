@@ -0,0 +1,31 @@
+package ratelimit_test
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestVetReportsCopies checks that the locks held by value in FixedWindow
+// and KeyedLimiter make go vet's copylocks check report copies of them.
+func TestVetReportsCopies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go vet")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(goTool, "vet", "-copylocks", "./testdata/copylocks").CombinedOutput()
+	if err == nil {
+		t.Fatalf("go vet passed, want copylocks reports; output:\n%s", out)
+	}
+	for _, want := range []string{
+		"fixedWindow passes lock by value: github.com/govi230/ratelimit.FixedWindow",
+		"assignment copies lock value to copied: github.com/govi230/ratelimit.KeyedLimiter",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("go vet output lacks %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import "time"
+
+// WithWarmup ramps the limit from initial up to the configured limit over d.
+func WithWarmup(d time.Duration, initial uint64) Option {
+	return func(fw *FixedWindow) {
+		fw.Warmup = d
+		fw.InitialLimit = initial
+	}
+}
+
+// EffectiveLimit returns the number of requests currently allowed per
+// window: Limit plus Burst, or less while the limiter is warming up.
+func (fw *FixedWindow) EffectiveLimit() uint64 {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.limitAt(fw.now())
+}
+
+// limitAt returns the limit in force at now, including Burst. fw.mu must be
+// held.
+func (fw *FixedWindow) limitAt(now time.Time) uint64 {
+	return fw.baseLimitAt(now) + fw.Burst
+}
+
+func (fw *FixedWindow) baseLimitAt(now time.Time) uint64 {
+	if fw.Warmup <= 0 || !fw.started {
+		return fw.Limit
+	}
+	elapsed := now.Sub(fw.startedAt)
+	if elapsed >= fw.Warmup {
+		return fw.Limit
+	}
+	// SetLimit may have lowered Limit below InitialLimit since Do.
+	initial := min(fw.InitialLimit, fw.Limit)
+	if elapsed <= 0 {
+		return initial
+	}
+	ramp := float64(fw.Limit-initial) * float64(elapsed) / float64(fw.Warmup)
+	return initial + uint64(ramp)
+}
@@ -0,0 +1,48 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/govi230/ratelimit"
+)
+
+func TestWarmupRamp(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 100, Warmup: time.Hour, InitialLimit: 20, Clock: clock})
+	for _, step := range []struct {
+		advance time.Duration
+		want    uint64
+	}{
+		{0, 20},
+		{15 * time.Minute, 40},
+		{15 * time.Minute, 60},
+		{15 * time.Minute, 80},
+		{15 * time.Minute, 100},
+		{time.Hour, 100},
+	} {
+		clock.Add(step.advance)
+		if got := fw.EffectiveLimit(); got != step.want {
+			t.Fatalf("after %v: limit %d, want %d", clock.Now().Sub(newFakeClock().Now()), got, step.want)
+		}
+	}
+}
+
+func TestWarmupSetLimitBelowInitial(t *testing.T) {
+	clock := newFakeClock()
+	fw := startFixedWindow(t, &ratelimit.FixedWindow{Window: time.Minute, Limit: 100, Warmup: time.Hour, InitialLimit: 50, Clock: clock})
+	clock.Add(30 * time.Minute)
+	if err := fw.SetLimit(10); err != nil {
+		t.Fatal(err)
+	}
+	if got := fw.EffectiveLimit(); got != 10 {
+		t.Fatalf("limit %d, want 10", got)
+	}
+	accepted := 0
+	for fw.Accept() {
+		accepted++
+	}
+	if accepted != 10 {
+		t.Fatalf("accepted %d, want 10", accepted)
+	}
+}
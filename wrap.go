@@ -0,0 +1,40 @@
+package ratelimit
+
+// Wrapper is implemented by limiters that wrap another one, such as those
+// returned by LoggingLimiter and TieredLimiter, so that the wrapped
+// limiter's WindowReporter and RetryReporter can still be found with As.
+//
+// A wrapper must implement ReasonAccepter itself rather than leave it to
+// As, since what it adds to Accept would otherwise be skipped.
+type Wrapper interface {
+	Unwrap() Limiter
+}
+
+// As walks rl and the limiters it wraps, outermost first, and returns the
+// first of them that implements T, e.g. As[WindowReporter](rl). The
+// middleware in this module uses it so that wrapping a limiter does not
+// lose its rate limit headers.
+func As[T any](rl Limiter) (T, bool) {
+	for rl != nil {
+		if t, ok := rl.(T); ok {
+			return t, true
+		}
+		w, ok := rl.(Wrapper)
+		if !ok {
+			break
+		}
+		rl = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// AcceptWithReason asks rl whether to accept a request. If rl implements
+// ReasonAccepter the reason is its own; otherwise it is ReasonOK or
+// ReasonLimitReached. Wrappers use it to ask the limiter they wrap.
+func AcceptWithReason(rl Limiter) (bool, Reason) {
+	if ra, ok := rl.(ReasonAccepter); ok {
+		return ra.AcceptWithReason()
+	}
+	return withReason(rl.Accept())
+}